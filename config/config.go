@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,11 +13,43 @@ type Config struct {
 	DatabaseURL    string
 	JWTSecret      string
 	FrontendOrigin string
+	Issuer         string
 
 	Admin struct {
 		JWTSecret string
 	}
 
+	// HTTP server timeouts
+	Server struct {
+		ReadHeaderTimeout time.Duration
+		ReadTimeout       time.Duration
+		WriteTimeout      time.Duration
+		IdleTimeout       time.Duration
+		ShutdownTimeout   time.Duration // How long in-flight requests may take to complete on shutdown
+	}
+
+	// Native TLS, enabled when a certificate is configured
+	TLS struct {
+		CertFile       string
+		KeyFile        string
+		ClientCAFile   string        // CAs issuing the certificates of tls_client_auth clients
+		MinVersion     string        // "1.2" or "1.3"
+		CipherSuites   []string      // crypto/tls suite names for TLS 1.2, empty for Go's defaults
+		RedirectPort   string        // Plain HTTP port redirecting to HTTPS, empty to disable
+		ReloadInterval time.Duration // How often certificate files are checked for changes, 0 to only reload on SIGHUP
+	}
+
+	// Token signing configuration
+	Signing struct {
+		Algorithm string // "RS256", "ES256", "EdDSA" or "HS256" (shared JWT secret)
+		KeyFile   string // PEM-encoded private key, generated on first start if missing
+
+		// Database-managed keys (used when KeyFile is empty)
+		EncryptionKey    string        // Encrypts private keys at rest
+		RotationInterval time.Duration // Lifetime of the active key before rotation
+		PublishDelay     time.Duration // Time a new key is published before it signs tokens
+	}
+
 	UserProvider struct {
 		Type string `json:"type"` // "sql", "rest"
 		// SQL Options
@@ -63,6 +96,72 @@ type Config struct {
 		IncludeRolesInJWT bool `json:"includeRolesInJWT,omitempty"`
 	}
 
+	// Access tokens, overridable per client
+	AccessToken struct {
+		Lifetime time.Duration
+	}
+
+	// Scopes granted to any client when a request names none (RFC 6749
+	// section 3.3), space-separated. Empty makes such requests fail with
+	// invalid_scope
+	DefaultScope string
+
+	// Client secrets generated by the admin API
+	ClientSecret struct {
+		Lifetime            time.Duration // How long a new secret is valid, 0 for unlimited
+		RotationGracePeriod time.Duration // How long the previous secret stays valid after a rotation
+	}
+
+	// Dynamic client registration (RFC 7591)
+	Registration struct {
+		Enabled             bool
+		InitialAccessTokens []string // Tokens allowed to register clients, empty for open registration
+	}
+
+	// Refresh token policy, overridable per client
+	RefreshToken struct {
+		Lifetime    time.Duration // Absolute lifetime of a grant, 0 for unlimited
+		IdleTimeout time.Duration // Maximum time between two uses, 0 for unlimited
+	}
+
+	// Background cleanup of expired tokens and codes
+	Sweeper struct {
+		Interval time.Duration
+	}
+
+	// Device authorization grant (RFC 8628)
+	DeviceCode struct {
+		Lifetime     time.Duration
+		PollInterval time.Duration
+	}
+
+	// Pushed authorization requests (RFC 9126)
+	PushedAuthorization struct {
+		Lifetime time.Duration // How long a request_uri can be used
+		Required bool          // Require PAR from every client, not only the ones configured to
+	}
+
+	// Browser SSO session at the authorization server
+	SSOSession struct {
+		Lifetime     time.Duration
+		CookieName   string
+		CookieSecure bool
+	}
+
+	// RP-initiated logout
+	Logout struct {
+		RevokeRefreshTokens bool // Revoke the user's refresh tokens for the client logging out
+
+		// Back-channel logout notifications
+		BackchannelTimeout  time.Duration
+		BackchannelAttempts int
+	}
+
+	// Token revocation
+	Revocation struct {
+		DenylistAccessTokens bool // Reject revoked access tokens until they expire
+	}
+
 	// Rate limiting configuration
 	RateLimit struct {
 		Enabled           bool
@@ -84,6 +183,48 @@ func Load() {
 		FrontendOrigin: getEnv("FRONTEND_ORIGIN", "http://localhost:3000"),
 	}
 
+	// TLS
+	App.TLS.CertFile = getEnv("TLS_CERT_FILE", "")
+	App.TLS.KeyFile = getEnv("TLS_KEY_FILE", "")
+	App.TLS.ClientCAFile = getEnv("TLS_CLIENT_CA_FILE", "")
+	App.TLS.MinVersion = getEnv("TLS_MIN_VERSION", "1.2")
+	for _, suite := range strings.Split(getEnv("TLS_CIPHER_SUITES", ""), ",") {
+		if suite = strings.TrimSpace(suite); suite != "" {
+			App.TLS.CipherSuites = append(App.TLS.CipherSuites, suite)
+		}
+	}
+	App.TLS.RedirectPort = getEnv("TLS_REDIRECT_PORT", "")
+	reloadSeconds := getEnvInt("TLS_RELOAD_INTERVAL_SECONDS", 60)
+	App.TLS.ReloadInterval = time.Duration(reloadSeconds) * time.Second
+
+	// HTTP server timeouts
+	readHeaderSeconds := getEnvInt("SERVER_READ_HEADER_TIMEOUT_SECONDS", 10)
+	App.Server.ReadHeaderTimeout = time.Duration(readHeaderSeconds) * time.Second
+	readSeconds := getEnvInt("SERVER_READ_TIMEOUT_SECONDS", 30)
+	App.Server.ReadTimeout = time.Duration(readSeconds) * time.Second
+	writeSeconds := getEnvInt("SERVER_WRITE_TIMEOUT_SECONDS", 30)
+	App.Server.WriteTimeout = time.Duration(writeSeconds) * time.Second
+	idleSeconds := getEnvInt("SERVER_IDLE_TIMEOUT_SECONDS", 120)
+	App.Server.IdleTimeout = time.Duration(idleSeconds) * time.Second
+	shutdownSeconds := getEnvInt("SERVER_SHUTDOWN_TIMEOUT_SECONDS", 30)
+	App.Server.ShutdownTimeout = time.Duration(shutdownSeconds) * time.Second
+
+	// Public issuer URL, used as "iss" in ID tokens
+	scheme := "http"
+	if App.TLS.CertFile != "" {
+		scheme = "https"
+	}
+	App.Issuer = getEnv("ISSUER_URL", scheme+"://localhost:"+App.ServerPort)
+
+	// Token signing
+	App.Signing.Algorithm = getEnv("SIGNING_ALGORITHM", "RS256")
+	App.Signing.KeyFile = getEnv("SIGNING_KEY_FILE", "")
+	App.Signing.EncryptionKey = getEnv("SIGNING_KEY_ENCRYPTION_KEY", App.JWTSecret)
+	rotationDays := getEnvInt("SIGNING_KEY_ROTATION_DAYS", 30)
+	App.Signing.RotationInterval = time.Duration(rotationDays) * 24 * time.Hour
+	publishHours := getEnvInt("SIGNING_KEY_PUBLISH_HOURS", 24)
+	App.Signing.PublishDelay = time.Duration(publishHours) * time.Hour
+
 	// Admin JWT secret
 	App.Admin.JWTSecret = getEnv("ADMIN_JWT_SECRET", App.JWTSecret)
 
@@ -125,6 +266,63 @@ func Load() {
 	App.RoleManager.UserGroupUserCol = getEnv("ROLE_MANAGER_USER_GROUP_USER_COL", "user_id")
 	App.RoleManager.UserGroupGroupCol = getEnv("ROLE_MANAGER_USER_GROUP_GROUP_COL", "group_id")
 
+	// Access tokens
+	accessSeconds := getEnvInt("ACCESS_TOKEN_LIFETIME_SECONDS", 3600)
+	App.AccessToken.Lifetime = time.Duration(accessSeconds) * time.Second
+
+	// Scopes
+	App.DefaultScope = getEnv("DEFAULT_SCOPE", "basic")
+
+	// Client secrets
+	secretLifetimeDays := getEnvInt("CLIENT_SECRET_LIFETIME_DAYS", 0)
+	App.ClientSecret.Lifetime = time.Duration(secretLifetimeDays) * 24 * time.Hour
+	graceHours := getEnvInt("CLIENT_SECRET_ROTATION_GRACE_HOURS", 24)
+	App.ClientSecret.RotationGracePeriod = time.Duration(graceHours) * time.Hour
+
+	// Dynamic client registration
+	App.Registration.Enabled = getEnvBool("REGISTRATION_ENABLED", false)
+	for _, token := range strings.Split(getEnv("REGISTRATION_INITIAL_ACCESS_TOKENS", ""), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			App.Registration.InitialAccessTokens = append(App.Registration.InitialAccessTokens, token)
+		}
+	}
+
+	// Refresh token policy
+	refreshLifetimeDays := getEnvInt("REFRESH_TOKEN_LIFETIME_DAYS", 30)
+	App.RefreshToken.Lifetime = time.Duration(refreshLifetimeDays) * 24 * time.Hour
+	refreshIdleDays := getEnvInt("REFRESH_TOKEN_IDLE_DAYS", 7)
+	App.RefreshToken.IdleTimeout = time.Duration(refreshIdleDays) * 24 * time.Hour
+
+	// Expired token cleanup
+	sweepMinutes := getEnvInt("TOKEN_SWEEP_INTERVAL_MINUTES", 60)
+	App.Sweeper.Interval = time.Duration(sweepMinutes) * time.Minute
+
+	// Device authorization grant
+	deviceMinutes := getEnvInt("DEVICE_CODE_LIFETIME_MINUTES", 10)
+	App.DeviceCode.Lifetime = time.Duration(deviceMinutes) * time.Minute
+	pollSeconds := getEnvInt("DEVICE_CODE_POLL_INTERVAL_SECONDS", 5)
+	App.DeviceCode.PollInterval = time.Duration(pollSeconds) * time.Second
+
+	// Pushed authorization requests
+	parSeconds := getEnvInt("PAR_REQUEST_LIFETIME_SECONDS", 90)
+	App.PushedAuthorization.Lifetime = time.Duration(parSeconds) * time.Second
+	App.PushedAuthorization.Required = getEnvBool("PAR_REQUIRED", false)
+
+	// SSO session
+	ssoHours := getEnvInt("SSO_SESSION_LIFETIME_HOURS", 12)
+	App.SSOSession.Lifetime = time.Duration(ssoHours) * time.Hour
+	App.SSOSession.CookieName = getEnv("SSO_SESSION_COOKIE_NAME", "zenauth_session")
+	App.SSOSession.CookieSecure = getEnvBool("SSO_SESSION_COOKIE_SECURE", strings.HasPrefix(App.Issuer, "https://"))
+
+	// Logout
+	App.Logout.RevokeRefreshTokens = getEnvBool("LOGOUT_REVOKE_REFRESH_TOKENS", false)
+	backchannelSeconds := getEnvInt("BACKCHANNEL_LOGOUT_TIMEOUT_SECONDS", 5)
+	App.Logout.BackchannelTimeout = time.Duration(backchannelSeconds) * time.Second
+	App.Logout.BackchannelAttempts = getEnvInt("BACKCHANNEL_LOGOUT_ATTEMPTS", 3)
+
+	// Token revocation
+	App.Revocation.DenylistAccessTokens = getEnvBool("REVOCATION_DENYLIST_ACCESS_TOKENS", true)
+
 	// Rate limiting configuration
 	App.RateLimit.Enabled = getEnvBool("RATE_LIMIT_ENABLED", true)
 	App.RateLimit.MaxAttempts = getEnvInt("RATE_LIMIT_MAX_ATTEMPTS", 5)
@@ -80,4 +80,223 @@ CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
 CREATE INDEX idx_refresh_tokens_issued_at ON refresh_tokens(issued_at);
 
 -- Extensions utiles
-CREATE EXTENSION IF NOT EXISTS "pgcrypto"; -- pour gen_random_uuid()
\ No newline at end of file
+CREATE EXTENSION IF NOT EXISTS "pgcrypto"; -- pour gen_random_uuid()
+-- OpenID Connect: nonce and authentication time carried by authorization codes
+ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
+ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP NOT NULL DEFAULT now();
+
+-- Token signing keys, private keys encrypted with SIGNING_KEY_ENCRYPTION_KEY
+CREATE TABLE IF NOT EXISTS signing_keys (
+  id TEXT PRIMARY KEY,
+  algorithm TEXT NOT NULL,
+  private_key BYTEA NOT NULL,
+  state TEXT NOT NULL DEFAULT 'pending',
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  activated_at TIMESTAMP,
+  retired_at TIMESTAMP,
+  expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_state ON signing_keys(state);
+
+-- Token revocation (RFC 7009)
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+  jti TEXT PRIMARY KEY,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- Index pour le nettoyage des entrées expirées
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+-- Refresh token rotation: tokens obtained from the same grant share a family
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+  id TEXT PRIMARY KEY,
+  type TEXT NOT NULL,
+  client_id TEXT,
+  user_id TEXT,
+  details TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+
+-- Refresh token expiry: absolute lifetime and idle timeout, overridable per client (seconds, 0 = server default)
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS refresh_token_lifetime INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS refresh_token_idle_timeout INTEGER NOT NULL DEFAULT 0;
+
+-- Registered scopes and the scopes each client may request (empty = any registered scope)
+CREATE TABLE IF NOT EXISTS scopes (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+INSERT INTO scopes (name, description) VALUES
+  ('openid', 'Sign you in with your account'),
+  ('profile', 'View your username'),
+  ('email', 'View your email address'),
+  ('read', 'Read your data'),
+  ('write', 'Modify your data')
+ON CONFLICT DO NOTHING;
+
+-- Default scope of requests naming none (DEFAULT_SCOPE), and of refresh
+-- tokens issued before scopes were tracked
+INSERT INTO scopes (name, description) VALUES
+  ('basic', 'Know that you use this application')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS allowed_scopes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+
+-- Scopes granted by users to clients, and authorization requests awaiting consent
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS consent_exempt BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS user_consents (
+  user_id TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, client_id)
+);
+
+CREATE TABLE IF NOT EXISTS consent_requests (
+  id TEXT PRIMARY KEY,
+  client_id TEXT NOT NULL,
+  redirect_uri TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  code_challenge TEXT NOT NULL DEFAULT '',
+  code_challenge_method TEXT NOT NULL DEFAULT '',
+  scope TEXT NOT NULL DEFAULT '',
+  nonce TEXT NOT NULL DEFAULT '',
+  state TEXT NOT NULL DEFAULT '',
+  auth_time TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- Browser SSO sessions at the authorization server
+CREATE TABLE IF NOT EXISTS sso_sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  auth_time TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_sessions_user_id ON sso_sessions(user_id);
+
+-- Registered destinations after RP-initiated logout
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+-- Back-channel and front-channel logout notification endpoints
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS backchannel_logout_uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS frontchannel_logout_uri TEXT NOT NULL DEFAULT '';
+
+-- Device authorization requests (RFC 8628)
+CREATE TABLE IF NOT EXISTS device_codes (
+  device_code TEXT PRIMARY KEY,
+  user_code TEXT NOT NULL UNIQUE,
+  client_id TEXT NOT NULL,
+  scope TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'pending',
+  user_id TEXT,
+  auth_time TIMESTAMP,
+  interval INTEGER NOT NULL,
+  last_polled_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- Token exchange (RFC 8693) policy: audiences each client may target
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS token_exchange_audiences TEXT[] NOT NULL DEFAULT '{}';
+
+
+-- Client authentication (RFC 7523): required token endpoint auth method, registered public keys
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS token_endpoint_auth_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS jwks TEXT NOT NULL DEFAULT '';
+
+-- jti of JWT assertions already used, kept until they expire to prevent replay
+CREATE TABLE IF NOT EXISTS client_assertion_jtis (
+  client_id TEXT NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (client_id, jti)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_assertion_jtis_expires_at ON client_assertion_jtis(expires_at);
+
+-- Hashed client secrets: existing plaintext secrets are hashed with bcrypt (pgcrypto),
+-- and a previous secret stays valid until it expires while a rotation is in progress
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS secret_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS secret_expires_at TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS previous_secret_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMP;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'clients' AND column_name = 'secret') THEN
+    UPDATE clients SET secret_hash = crypt(secret, gen_salt('bf', 10)) WHERE secret_hash = '' AND secret <> '';
+    ALTER TABLE clients DROP COLUMN secret;
+  END IF;
+END $$;
+
+-- Dynamic client registration (RFC 7591/7592)
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS grant_types TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS registration_access_token_hash TEXT NOT NULL DEFAULT '';
+
+-- Client metadata: client type, per-client access token lifetime (seconds, 0 = server default)
+-- and the branding shown on the login page
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'confidential';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS access_token_lifetime INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS logo_uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS client_uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS policy_uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tos_uri TEXT NOT NULL DEFAULT '';
+UPDATE clients SET type = 'public' WHERE token_endpoint_auth_method = 'none';
+
+-- Pushed authorization requests (RFC 9126)
+CREATE TABLE IF NOT EXISTS pushed_authorization_requests (
+  request_uri TEXT PRIMARY KEY,
+  client_id TEXT NOT NULL,
+  parameters TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- JWT-secured authorization requests (RFC 9101): URIs request objects are fetched from
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS request_uris TEXT[] NOT NULL DEFAULT '{}';
+
+-- DPoP (RFC 9449): per-client requirement and the key refresh tokens are bound to
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS dpop_bound_access_tokens BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS dpop_jkt TEXT NOT NULL DEFAULT '';
+
+-- Mutual-TLS client authentication (RFC 8705)
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tls_client_auth_subject_dn TEXT NOT NULL DEFAULT '';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tls_client_certificate_thumbprints TEXT[] NOT NULL DEFAULT '{}';
+
+
+-- Users asserted with the jwt-bearer grant (RFC 7523)
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS jwt_bearer_user_assertions BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Encrypted client secrets, verifying client_secret_jwt assertions
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS encrypted_secret BYTEA;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS previous_encrypted_secret BYTEA;
+
+-- jti of DPoP proofs already used, shared by every instance to prevent replay
+CREATE TABLE IF NOT EXISTS dpop_proof_jtis (
+  jkt TEXT NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (jkt, jti)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dpop_proof_jtis_expires_at ON dpop_proof_jtis(expires_at);
@@ -6,6 +6,9 @@ import (
 	"zenauth/config"
 )
 
+// Connection pool of the role manager
+var managerDB *sql.DB
+
 func InitRoleManager() error {
 	roleManagerType := config.App.RoleManager.Type
 
@@ -31,9 +34,11 @@ func initLocalRoleManager() error {
 
 	manager, err := NewLocalManager(db)
 	if err != nil {
+		db.Close()
 		return err
 	}
 
+	managerDB = db
 	CurrentManager = manager
 	return nil
 }
@@ -72,6 +77,15 @@ func initExternalRoleManager() error {
 	}
 
 	manager := NewExternalManager(db, config)
+	managerDB = db
 	CurrentManager = manager
 	return nil
 }
+
+// CloseRoleManager closes the connection pool of the role manager
+func CloseRoleManager() error {
+	if managerDB == nil {
+		return nil
+	}
+	return managerDB.Close()
+}
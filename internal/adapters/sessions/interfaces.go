@@ -19,4 +19,6 @@ type Limiter interface {
 	RecordUserIP(username, ip string) error
 	GetIPsForUser(username string) ([]string, error)
 	GetUsersForIP(ip string) ([]string, error)
+
+	Close() error
 }
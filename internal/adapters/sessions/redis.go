@@ -218,3 +218,8 @@ func (r *RedisLimiter) GetUsersForIP(ipAddress string) ([]string, error) {
 
 	return users, nil
 }
+
+// Close closes the connections to Redis
+func (r *RedisLimiter) Close() error {
+	return r.client.Close()
+}
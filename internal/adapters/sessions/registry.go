@@ -52,6 +52,14 @@ func RegisterLimiter(limiter Limiter) {
 	CurrentLimiter = limiter
 }
 
+// CloseSessions closes the connections of the session store
+func CloseSessions() error {
+	if CurrentLimiter == nil {
+		return nil
+	}
+	return CurrentLimiter.Close()
+}
+
 // IsLimiterEnabled checks if rate limiting is enabled and configured
 func IsLimiterEnabled() bool {
 	return config.App.RateLimit.Enabled && CurrentLimiter != nil
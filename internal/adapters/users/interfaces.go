@@ -5,6 +5,8 @@ import (
 )
 
 type UserProvider interface {
+	GetUserByID(id string) (*models.User, error)
+
 	GetUserByUsername(username string) (*models.User, error)
 
 	GetUserByEmail(email string) (*models.User, error)
@@ -9,6 +9,9 @@ import (
 
 var (
 	CurrentUserProvider UserProvider
+
+	// Connection pool of the SQL user provider
+	providerDB *sql.DB
 )
 
 func InitUserProvider() error {
@@ -35,6 +38,7 @@ func initSQLUserProvider() error {
 		return err
 	}
 
+	providerDB = db
 	CurrentUserProvider = NewSQLUser(
 		db,
 		config.App.UserProvider.SQLTable,
@@ -48,6 +52,14 @@ func initSQLUserProvider() error {
 	return nil
 }
 
+// CloseUserProvider releases the resources of the user provider
+func CloseUserProvider() error {
+	if providerDB == nil {
+		return nil
+	}
+	return providerDB.Close()
+}
+
 func initRESTUserProvider() error {
 	return errors.New("REST User Provider not implemented yet")
 }
@@ -28,6 +28,35 @@ func NewSQLUser(db *sql.DB, tableName, idField, usernameField, passwordHashField
 	}
 }
 
+func (p *SQLUser) GetUserByID(id string) (*models.User, error) {
+	query := fmt.Sprintf(
+		"SELECT %s, %s, %s, %s FROM %s WHERE %s = $1",
+		p.idField, p.usernameField, p.passwordHashField, p.emailField,
+		p.tableName, p.idField,
+	)
+
+	row := p.db.QueryRow(query, id)
+
+	var user models.User
+	var email sql.NullString
+	var passwordHash sql.NullString
+
+	err := row.Scan(&user.ID, &user.Username, &passwordHash, &email)
+	if err != nil {
+		return nil, err
+	}
+
+	if email.Valid {
+		user.Email = email.String
+	}
+
+	if passwordHash.Valid {
+		user.PasswordHash = passwordHash.String
+	}
+
+	return &user, nil
+}
+
 func (p *SQLUser) GetUserByUsername(username string) (*models.User, error) {
 	query := fmt.Sprintf(
 		"SELECT %s, %s, %s, %s FROM %s WHERE %s = $1",
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"html/template"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	sProviders "zenauth/internal/adapters/sessions"
 	uProviders "zenauth/internal/adapters/users"
 	"zenauth/internal/models"
+	"zenauth/internal/oauth"
 	"zenauth/internal/repositories"
 
 	"github.com/golang-jwt/jwt"
@@ -90,6 +92,20 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/", http.StatusSeeOther)
 }
 
+// AdminLogoutHandler clears the admin session cookie
+func AdminLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "admin_token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		MaxAge:   -1,
+	})
+
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
 // Admin authentication middleware
 func AdminAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -427,6 +443,13 @@ func updateUser(w http.ResponseWriter, r *http.Request, id string) {
 }
 
 func deleteUser(w http.ResponseWriter, r *http.Request, id string) {
+	// Sign the user out everywhere before the account disappears
+	clients, err := oauth.LogoutUser(id)
+	if err != nil {
+		log.Printf("Failed to log out user %s: %v", id, err)
+	}
+	oauth.NotifyBackchannelLogout(id, clients)
+
 	if err := repositories.DeleteUser(id); err != nil {
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
@@ -449,10 +472,32 @@ func listClients(w http.ResponseWriter, r *http.Request) {
 
 func createClient(w http.ResponseWriter, r *http.Request) {
 	var data struct {
-		ID           string   `json:"id"`
-		Name         string   `json:"name"`
-		Secret       string   `json:"secret"`
-		RedirectURIs []string `json:"redirect_uris"`
+		ID                      string   `json:"id"`
+		Name                    string   `json:"name"`
+		RedirectURIs            []string `json:"redirect_uris"`
+		RefreshTokenLifetime    int      `json:"refresh_token_lifetime"`
+		RefreshTokenIdleTimeout int      `json:"refresh_token_idle_timeout"`
+		AllowedScopes           []string `json:"allowed_scopes"`
+		ConsentExempt           bool     `json:"consent_exempt"`
+		PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris"`
+		BackchannelLogoutURI    string   `json:"backchannel_logout_uri"`
+		FrontchannelLogoutURI   string   `json:"frontchannel_logout_uri"`
+		TokenExchangeAudiences  []string `json:"token_exchange_audiences"`
+		TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+		JWKS                    string   `json:"jwks"`
+		Type                    string   `json:"type"`
+		GrantTypes              []string `json:"grant_types"`
+		AccessTokenLifetime     int      `json:"access_token_lifetime"`
+		LogoURI                 string   `json:"logo_uri"`
+		ClientURI               string   `json:"client_uri"`
+		PolicyURI               string   `json:"policy_uri"`
+		TosURI                  string   `json:"tos_uri"`
+		RequirePAR              bool     `json:"require_pushed_authorization_requests"`
+		RequestURIs             []string `json:"request_uris"`
+		DPoPBoundAccessTokens   bool     `json:"dpop_bound_access_tokens"`
+		TLSClientAuthSubjectDN  string   `json:"tls_client_auth_subject_dn"`
+		TLSClientThumbprints    []string `json:"tls_client_certificate_thumbprints"`
+		JWTBearerUserAssertions bool     `json:"jwt_bearer_user_assertions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -460,12 +505,72 @@ func createClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if data.ID == "" || data.Name == "" || data.Secret == "" {
-		http.Error(w, "Client ID, name, and secret are required", http.StatusBadRequest)
+	if data.ID == "" || data.Name == "" {
+		http.Error(w, "Client ID and name are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := checkScopesExist(data.AllowedScopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := &models.Client{
+		ID:                      data.ID,
+		Name:                    data.Name,
+		RedirectURIs:            data.RedirectURIs,
+		RefreshTokenLifetime:    data.RefreshTokenLifetime,
+		RefreshTokenIdleTimeout: data.RefreshTokenIdleTimeout,
+		AllowedScopes:           data.AllowedScopes,
+		ConsentExempt:           data.ConsentExempt,
+		PostLogoutRedirectURIs:  data.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:    data.BackchannelLogoutURI,
+		FrontchannelLogoutURI:   data.FrontchannelLogoutURI,
+		TokenExchangeAudiences:  data.TokenExchangeAudiences,
+		TokenEndpointAuthMethod: data.TokenEndpointAuthMethod,
+		JWKS:                    data.JWKS,
+		Type:                    models.ClientType(data.Type),
+		GrantTypes:              data.GrantTypes,
+		AccessTokenLifetime:     data.AccessTokenLifetime,
+		LogoURI:                 data.LogoURI,
+		ClientURI:               data.ClientURI,
+		PolicyURI:               data.PolicyURI,
+		TosURI:                  data.TosURI,
+
+		RequirePushedAuthorizationRequests: data.RequirePAR,
+		RequestURIs:                        data.RequestURIs,
+		DPoPBoundAccessTokens:              data.DPoPBoundAccessTokens,
+		TLSClientAuthSubjectDN:             data.TLSClientAuthSubjectDN,
+		TLSClientCertificateThumbprints:    data.TLSClientThumbprints,
+		JWTBearerUserAssertions:            data.JWTBearerUserAssertions,
+	}
+
+	if err := oauth.CheckClientPolicy(client); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := oauth.ValidateClientAuthSettings(client.TokenEndpointAuthMethod, client.JWKS); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	client, err := repositories.CreateClient(data.ID, data.Secret, data.Name, data.RedirectURIs)
+	if err := oauth.ValidateClientCertificateSettings(client.TokenEndpointAuthMethod, client.TLSClientAuthSubjectDN, client.TLSClientCertificateThumbprints); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The secret is generated here and only ever shown in this response
+	var secret string
+	if oauth.ClientNeedsSecret(client.TokenEndpointAuthMethod) {
+		var err error
+		if secret, err = oauth.SetClientSecret(client); err != nil {
+			http.Error(w, "Failed to generate client secret", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	client, err := repositories.CreateClient(client)
 	if err != nil {
 		http.Error(w, "Failed to create client", http.StatusInternalServerError)
 		return
@@ -473,7 +578,10 @@ func createClient(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(client)
+	json.NewEncoder(w).Encode(struct {
+		*models.Client
+		Secret string `json:"secret,omitempty"`
+	}{client, secret})
 }
 
 func getClient(w http.ResponseWriter, r *http.Request, id string) {
@@ -489,9 +597,34 @@ func getClient(w http.ResponseWriter, r *http.Request, id string) {
 
 func updateClient(w http.ResponseWriter, r *http.Request, id string) {
 	var data struct {
-		Name         string   `json:"name"`
-		Secret       *string  `json:"secret,omitempty"`
-		RedirectURIs []string `json:"redirect_uris"`
+		Name                    string    `json:"name"`
+		RotateSecret            bool      `json:"rotate_secret,omitempty"`
+		RevokePreviousSecret    bool      `json:"revoke_previous_secret,omitempty"`
+		PreviousSecretTTL       *int      `json:"previous_secret_ttl,omitempty"` // seconds
+		RedirectURIs            []string  `json:"redirect_uris"`
+		RefreshTokenLifetime    *int      `json:"refresh_token_lifetime,omitempty"`
+		RefreshTokenIdleTimeout *int      `json:"refresh_token_idle_timeout,omitempty"`
+		AllowedScopes           *[]string `json:"allowed_scopes,omitempty"`
+		ConsentExempt           *bool     `json:"consent_exempt,omitempty"`
+		PostLogoutRedirectURIs  *[]string `json:"post_logout_redirect_uris,omitempty"`
+		BackchannelLogoutURI    *string   `json:"backchannel_logout_uri,omitempty"`
+		FrontchannelLogoutURI   *string   `json:"frontchannel_logout_uri,omitempty"`
+		TokenExchangeAudiences  *[]string `json:"token_exchange_audiences,omitempty"`
+		TokenEndpointAuthMethod *string   `json:"token_endpoint_auth_method,omitempty"`
+		JWKS                    *string   `json:"jwks,omitempty"`
+		Type                    *string   `json:"type,omitempty"`
+		GrantTypes              *[]string `json:"grant_types,omitempty"`
+		AccessTokenLifetime     *int      `json:"access_token_lifetime,omitempty"`
+		LogoURI                 *string   `json:"logo_uri,omitempty"`
+		ClientURI               *string   `json:"client_uri,omitempty"`
+		PolicyURI               *string   `json:"policy_uri,omitempty"`
+		TosURI                  *string   `json:"tos_uri,omitempty"`
+		RequirePAR              *bool     `json:"require_pushed_authorization_requests,omitempty"`
+		RequestURIs             *[]string `json:"request_uris,omitempty"`
+		DPoPBoundAccessTokens   *bool     `json:"dpop_bound_access_tokens,omitempty"`
+		TLSClientAuthSubjectDN  *string   `json:"tls_client_auth_subject_dn,omitempty"`
+		TLSClientThumbprints    *[]string `json:"tls_client_certificate_thumbprints,omitempty"`
+		JWTBearerUserAssertions *bool     `json:"jwt_bearer_user_assertions,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -504,16 +637,149 @@ func updateClient(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
-	if err := repositories.UpdateClient(id, data.Name, data.Secret, data.RedirectURIs); err != nil {
+	client, err := repositories.GetClientByID(id)
+	if err != nil {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+
+	// Settings that are not sent keep their current value
+	client.Name = data.Name
+	client.RedirectURIs = data.RedirectURIs
+	if data.RefreshTokenLifetime != nil {
+		client.RefreshTokenLifetime = *data.RefreshTokenLifetime
+	}
+	if data.RefreshTokenIdleTimeout != nil {
+		client.RefreshTokenIdleTimeout = *data.RefreshTokenIdleTimeout
+	}
+	if data.AllowedScopes != nil {
+		if err := checkScopesExist(*data.AllowedScopes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		client.AllowedScopes = *data.AllowedScopes
+	}
+	if data.ConsentExempt != nil {
+		client.ConsentExempt = *data.ConsentExempt
+	}
+	if data.PostLogoutRedirectURIs != nil {
+		client.PostLogoutRedirectURIs = *data.PostLogoutRedirectURIs
+	}
+	if data.BackchannelLogoutURI != nil {
+		client.BackchannelLogoutURI = *data.BackchannelLogoutURI
+	}
+	if data.FrontchannelLogoutURI != nil {
+		client.FrontchannelLogoutURI = *data.FrontchannelLogoutURI
+	}
+	if data.TokenExchangeAudiences != nil {
+		client.TokenExchangeAudiences = *data.TokenExchangeAudiences
+	}
+	if data.TokenEndpointAuthMethod != nil {
+		client.TokenEndpointAuthMethod = *data.TokenEndpointAuthMethod
+	}
+	if data.JWKS != nil {
+		client.JWKS = *data.JWKS
+	}
+	if data.Type != nil {
+		client.Type = models.ClientType(*data.Type)
+	}
+	if data.GrantTypes != nil {
+		client.GrantTypes = *data.GrantTypes
+	}
+	if data.AccessTokenLifetime != nil {
+		client.AccessTokenLifetime = *data.AccessTokenLifetime
+	}
+	if data.LogoURI != nil {
+		client.LogoURI = *data.LogoURI
+	}
+	if data.ClientURI != nil {
+		client.ClientURI = *data.ClientURI
+	}
+	if data.PolicyURI != nil {
+		client.PolicyURI = *data.PolicyURI
+	}
+	if data.TosURI != nil {
+		client.TosURI = *data.TosURI
+	}
+	if data.RequirePAR != nil {
+		client.RequirePushedAuthorizationRequests = *data.RequirePAR
+	}
+	if data.RequestURIs != nil {
+		client.RequestURIs = *data.RequestURIs
+	}
+	if data.DPoPBoundAccessTokens != nil {
+		client.DPoPBoundAccessTokens = *data.DPoPBoundAccessTokens
+	}
+	if data.TLSClientAuthSubjectDN != nil {
+		client.TLSClientAuthSubjectDN = *data.TLSClientAuthSubjectDN
+	}
+	if data.TLSClientThumbprints != nil {
+		client.TLSClientCertificateThumbprints = *data.TLSClientThumbprints
+	}
+	if data.JWTBearerUserAssertions != nil {
+		client.JWTBearerUserAssertions = *data.JWTBearerUserAssertions
+	}
+
+	if err := oauth.CheckClientPolicy(client); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := oauth.ValidateClientAuthSettings(client.TokenEndpointAuthMethod, client.JWKS); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := oauth.ValidateClientCertificateSettings(client.TokenEndpointAuthMethod, client.TLSClientAuthSubjectDN, client.TLSClientCertificateThumbprints); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if data.RevokePreviousSecret {
+		oauth.RevokePreviousClientSecret(client)
+	}
+
+	// Public clients have no secret at all
+	if !oauth.ClientNeedsSecret(client.TokenEndpointAuthMethod) && data.RotateSecret {
+		http.Error(w, "This client does not use a secret", http.StatusBadRequest)
+		return
+	}
+	if client.IsPublic() {
+		client.SecretHash, client.SecretExpiresAt = "", nil
+		oauth.RevokePreviousClientSecret(client)
+	}
+
+	// A client switching to a secret based method gets its first secret, and
+	// one switching to client_secret_jwt a secret it can sign assertions with
+	oauth.DiscardEncryptedClientSecrets(client)
+	var secret string
+	if data.RotateSecret || oauth.ClientNeedsNewSecret(client) {
+		grace := config.App.ClientSecret.RotationGracePeriod
+		if data.PreviousSecretTTL != nil {
+			grace = time.Duration(*data.PreviousSecretTTL) * time.Second
+		}
+		if secret, err = oauth.RotateClientSecret(client, grace); err != nil {
+			http.Error(w, "Failed to generate client secret", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := repositories.UpdateClient(client); err != nil {
 		http.Error(w, "Failed to update client", http.StatusInternalServerError)
 		return
 	}
 
+	resp := map[string]interface{}{
+		"message": "Client updated successfully",
+	}
+	if secret != "" {
+		resp["secret"] = secret
+		resp["previous_secret_expires_at"] = client.PreviousSecretExpiresAt
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Client updated successfully",
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 func deleteClient(w http.ResponseWriter, r *http.Request, id string) {
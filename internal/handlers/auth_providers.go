@@ -6,14 +6,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"time"
 	"zenauth/config"
 	adapters "zenauth/internal/adapters/auth_providers"
 	userAdapters "zenauth/internal/adapters/users"
 	"zenauth/internal/models"
 	"zenauth/internal/repositories"
-
-	"github.com/google/uuid"
 )
 
 // StartExternalAuth initiates OAuth flow with the specified provider
@@ -25,16 +22,19 @@ func StartExternalAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parameters pushed by the client replace the query
+	req, ok := resolveAuthorizeRequest(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
 	// Validate the redirect URL for the OAuth flow
-	redirectURI := r.URL.Query().Get("redirect_uri")
-	if redirectURI == "" {
+	if req.RedirectURI == "" {
 		http.Error(w, "redirect_uri parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	// Get client ID
-	clientID := r.URL.Query().Get("client_id")
-	if clientID == "" {
+	if req.ClientID == "" {
 		http.Error(w, "client_id parameter is required", http.StatusBadRequest)
 		return
 	}
@@ -59,23 +59,19 @@ func StartExternalAuth(w http.ResponseWriter, r *http.Request) {
 		MaxAge:   600, // 10 minutes
 	})
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "client_id",
-		Value:    clientID,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   600, // 10 minutes
-	})
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "redirect_uri",
-		Value:    redirectURI,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   600, // 10 minutes
-	})
+	// The authorization request is kept until the callback. When it was
+	// pushed or signed, only its request_uri or request object is, and it is
+	// resolved again there
+	for _, c := range authorizeRequestCookies(req) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     c.name,
+			Value:    c.value,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   600, // 10 minutes
+		})
+	}
 
 	// Get the provider configuration
 	provider, err := repositories.GetAuthProviderByID(providerID)
@@ -142,19 +138,26 @@ func HandleExternalAuthCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the original OAuth parameters
-	clientIDCookie, err := r.Cookie("client_id")
-	if err != nil {
+	params := url.Values{}
+	for _, c := range authorizeRequestCookies(&authorizeRequest{}) {
+		if cookie, err := r.Cookie(c.name); err == nil {
+			params.Set(c.name, cookie.Value)
+		}
+	}
+	if params.Get("client_id") == "" {
 		http.Error(w, "Missing client_id", http.StatusBadRequest)
 		return
 	}
-	clientID := clientIDCookie.Value
 
-	redirectURICookie, err := r.Cookie("redirect_uri")
-	if err != nil {
-		http.Error(w, "Missing redirect_uri", http.StatusBadRequest)
+	req, ok := resolveAuthorizeRequest(w, params)
+	if !ok {
+		return
+	}
+
+	client, scope, ok := validateAuthorizeRequest(w, req)
+	if !ok {
 		return
 	}
-	redirectURI := redirectURICookie.Value
 
 	// Get authorization code from query parameters
 	code := r.URL.Query().Get("code")
@@ -240,26 +243,54 @@ func HandleExternalAuthCallback(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Generate an auth code for the OAuth flow
-	authCode := uuid.NewString()
-	err = repositories.StoreAuthCode(&models.AuthCode{
-		Code:        authCode,
-		ClientID:    clientID,
-		RedirectURI: redirectURI,
-		UserID:      user.ID,
-		ExpiresAt:   time.Now().Add(10 * time.Minute),
-		Scope:       "openid profile email",
-	})
+	// Clear cookies
+	http.SetCookie(w, &http.Cookie{Name: "oauth_state", MaxAge: -1, Path: "/"})
+	for _, c := range authorizeRequestCookies(&authorizeRequest{}) {
+		http.SetCookie(w, &http.Cookie{Name: c.name, MaxAge: -1, Path: "/"})
+	}
+
+	// Sign the user in at the authorization server for later requests
+	session, err := startSSOSession(w, r, user.ID)
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Clear cookies
-	http.SetCookie(w, &http.Cookie{Name: "oauth_state", MaxAge: -1, Path: "/"})
-	http.SetCookie(w, &http.Cookie{Name: "client_id", MaxAge: -1, Path: "/"})
-	http.SetCookie(w, &http.Cookie{Name: "redirect_uri", MaxAge: -1, Path: "/"})
+	// Redirect back to the client with an auth code, once the user consented
+	req.consumePushedRequest()
+	completeAuthorization(w, r, client, req.authCode(user.ID, scope, session.AuthTime), "", "")
+}
+
+type authorizeCookie struct {
+	name  string
+	value string
+}
 
-	// Redirect back to the client with the auth code
-	http.Redirect(w, r, redirectURI+"?code="+authCode, http.StatusFound)
+// authorizeRequestCookies lists the cookies carrying an authorization request
+// through an external login
+func authorizeRequestCookies(req *authorizeRequest) []authorizeCookie {
+	if req.RequestURI != "" {
+		return []authorizeCookie{
+			{"client_id", req.ClientID},
+			{"request_uri", req.RequestURI},
+			{"request", ""},
+		}
+	}
+	if req.Request != "" {
+		return []authorizeCookie{
+			{"client_id", req.ClientID},
+			{"request_uri", ""},
+			{"request", req.Request},
+		}
+	}
+	return []authorizeCookie{
+		{"client_id", req.ClientID},
+		{"redirect_uri", req.RedirectURI},
+		{"nonce", req.Nonce}, // OpenID Connect nonce, echoed back in the id_token
+		{"scope", req.Scope},
+		{"code_challenge", req.CodeChallenge}, // PKCE challenge, bound to the code issued after the callback
+		{"code_challenge_method", req.CodeChallengeMethod},
+		{"request_uri", ""},
+		{"request", ""},
+	}
 }
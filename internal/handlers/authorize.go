@@ -4,182 +4,412 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 	sessionsAdapters "zenauth/internal/adapters/sessions"
 	adapters "zenauth/internal/adapters/users"
 	"zenauth/internal/models"
+	"zenauth/internal/oauth"
 	"zenauth/internal/repositories"
-
-	"github.com/google/uuid"
 )
 
 var loginTmpl = template.Must(template.ParseFiles("templates/login.html.tmpl"))
 
+// authorizeRequest holds the parameters of an authorization request
+type authorizeRequest struct {
+	ClientID            string
+	RedirectURI         string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Scope               string
+	State               string
+	Nonce               string
+	Prompt              string
+	MaxAge              string
+
+	// request_uri from the PAR endpoint (RFC 9126) or where the request
+	// object was fetched from, and the request object (RFC 9101)
+	RequestURI string
+	Request    string
+
+	// Set when the parameters were pushed to the PAR endpoint
+	Pushed bool
+}
+
+func parseAuthorizeRequest(v url.Values) *authorizeRequest {
+	return &authorizeRequest{
+		ClientID:            v.Get("client_id"),
+		RedirectURI:         v.Get("redirect_uri"),
+		CodeChallenge:       v.Get("code_challenge"),
+		CodeChallengeMethod: v.Get("code_challenge_method"),
+		Scope:               v.Get("scope"),
+		State:               v.Get("state"),
+		Nonce:               v.Get("nonce"),
+		Prompt:              v.Get("prompt"),
+		MaxAge:              v.Get("max_age"),
+		RequestURI:          v.Get("request_uri"),
+		Request:             v.Get("request"),
+	}
+}
+
+// resolveAuthorizeRequest parses the parameters of an authorization request.
+// A request_uri is replaced by the parameters the client pushed under it, or
+// by the request object published there. A request object is verified, and
+// only its parameters are used. Errors are written to w
+func resolveAuthorizeRequest(w http.ResponseWriter, v url.Values) (*authorizeRequest, bool) {
+	params := v
+	requestURI := v.Get("request_uri")
+	pushed := strings.HasPrefix(requestURI, oauth.RequestURIPrefix)
+
+	switch {
+	case pushed:
+		var err error
+		if params, err = oauth.ResolvePushedAuthorizationRequest(requestURI, v.Get("client_id")); err != nil {
+			http.Error(w, "invalid_request_uri", http.StatusBadRequest)
+			return nil, false
+		}
+
+	case requestURI != "":
+		if v.Get("request") != "" {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return nil, false
+		}
+		client, err := repositories.GetClientByID(v.Get("client_id"))
+		if err != nil {
+			http.Error(w, "unauthorized_client", http.StatusBadRequest)
+			return nil, false
+		}
+		requestObject, err := oauth.FetchRequestObject(client, requestURI)
+		if err != nil {
+			http.Error(w, "invalid_request_uri", http.StatusBadRequest)
+			return nil, false
+		}
+		params = url.Values{}
+		for name, values := range v {
+			params[name] = values
+		}
+		params.Set("request", requestObject)
+	}
+
+	if params.Get("request") != "" {
+		var ok bool
+		if params, ok = verifyRequestObject(w, params); !ok {
+			return nil, false
+		}
+	}
+
+	req := parseAuthorizeRequest(params)
+	req.RequestURI = requestURI
+	req.Pushed = pushed
+	return req, true
+}
+
+// verifyRequestObject replaces the parameters by the ones of the signed
+// request object they carry. Errors are written to w
+func verifyRequestObject(w http.ResponseWriter, v url.Values) (url.Values, bool) {
+	client, err := repositories.GetClientByID(v.Get("client_id"))
+	if err != nil {
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return nil, false
+	}
+
+	params, err := oauth.VerifyRequestObject(client, v.Get("request"), v)
+	if err == oauth.ErrRequestParamMismatch {
+		http.Error(w, "invalid_request (parameters do not match the request object)", http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "invalid_request_object", http.StatusBadRequest)
+		return nil, false
+	}
+	return params, true
+}
+
+// consumePushedRequest ends the use of the request_uri of a pushed request,
+// once its parameters were carried over to a code or a consent request
+func (req *authorizeRequest) consumePushedRequest() {
+	if !req.Pushed {
+		return
+	}
+	if err := oauth.ConsumePushedAuthorizationRequest(req.RequestURI); err != nil {
+		log.Printf("Failed to delete pushed authorization request: %v", err)
+	}
+}
+
+// authCode builds the code issued for the request once the user is known
+func (req *authorizeRequest) authCode(userID, scope string, authTime time.Time) *models.AuthCode {
+	return &models.AuthCode{
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		UserID:              userID,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Scope:               scope,
+		Nonce:               req.Nonce,
+		AuthTime:            authTime,
+	}
+}
+
+// reusesSession reports whether the SSO session satisfies the request, which
+// is not the case when the client asks for a fresh login or the user
+// authenticated longer than max_age seconds ago
+func (req *authorizeRequest) reusesSession(session *models.SSOSession) bool {
+	if session == nil || hasPrompt(req.Prompt, "login") {
+		return false
+	}
+
+	if req.MaxAge != "" {
+		maxAge, err := strconv.Atoi(req.MaxAge)
+		if err == nil && time.Since(session.AuthTime) > time.Duration(maxAge)*time.Second {
+			return false
+		}
+	}
+	return true
+}
+
 func AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		appName := r.URL.Query().Get("client_id")
-		redirectURI := r.URL.Query().Get("redirect_uri")
-		codeChallenge := r.URL.Query().Get("code_challenge")
-		codeMethod := r.URL.Query().Get("code_challenge_method")
-		scopes := r.URL.Query().Get("scope")
-		logo := "/logo.png"
-
-		// Get enabled external providers
-		externalProviders, err := repositories.GetEnabledAuthProviders()
-		if err != nil {
-			log.Printf("Failed to get external providers: %v", err)
-			// Continue without external providers
-			externalProviders = []models.AuthProvider{}
-		}
-
-		loginTmpl.Execute(w, map[string]interface{}{
-			"ClientID":            appName,
-			"RedirectURI":         redirectURI,
-			"CodeChallenge":       codeChallenge,
-			"CodeChallengeMethod": codeMethod,
-			"Logo":                logo,
-			"Scope":               scopes,
-			"ExternalProviders":   externalProviders,
-		})
+		req, ok := resolveAuthorizeRequest(w, r.URL.Query())
+		if !ok {
+			return
+		}
+
+		// Users with an SSO session skip the login form
+		session := currentSSOSession(r)
+		if !req.reusesSession(session) {
+			session = nil
+		}
+
+		if session != nil || hasPrompt(req.Prompt, "none") {
+			client, scope, ok := validateAuthorizeRequest(w, req)
+			if !ok {
+				return
+			}
+
+			if session == nil {
+				redirectError(w, r, req.RedirectURI, "login_required", req.State)
+				return
+			}
+
+			req.consumePushedRequest()
+			completeAuthorization(w, r, client, req.authCode(session.UserID, scope, session.AuthTime), req.State, req.Prompt)
+			return
+		}
+
+		loginTmpl.Execute(w, loginData(req))
 		return
 	}
 
 	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+
+		req, ok := resolveAuthorizeRequest(w, r.Form)
+		if !ok {
+			return
+		}
 		identifier := r.FormValue("identifier")
 		password := r.FormValue("password")
-		redirectURI := r.FormValue("redirect_uri")
-		clientID := r.FormValue("client_id")
-		codeChallenge := r.FormValue("code_challenge")
-		codeMethod := r.FormValue("code_challenge_method")
-		scope := r.FormValue("scope")
-		state := r.FormValue("state")
 
 		ipAddress := getClientIP(r)
-		blocked, message, err := sessionsAdapters.CheckRateLimit(ipAddress)
-		if err != nil {
-			log.Printf("Rate limiting error: %v", err)
-		} else if blocked {
-			data := loginData(clientID, redirectURI, codeChallenge, codeMethod, scope, state)
+		if blocked, message := checkLoginRateLimit(ipAddress, identifier); blocked {
+			data := loginData(req)
 			data["Error"] = message
 			loginTmpl.Execute(w, data)
 			return
 		}
 
-		if identifier != "" {
-			userKey := "user:" + identifier
-			blocked, message, err := sessionsAdapters.CheckRateLimit(userKey)
-			if err != nil {
-				log.Printf("Rate limiting error: %v", err)
-			} else if blocked {
-				data := loginData(clientID, redirectURI, codeChallenge, codeMethod, scope, state)
-				data["Error"] = message
-				loginTmpl.Execute(w, data)
-				return
-			}
+		client, scope, ok := validateAuthorizeRequest(w, req)
+		if !ok {
+			return
 		}
 
-		client, err := repositories.GetClientByID(clientID)
-		if err != nil {
-			http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		user, ok := authenticateUser(ipAddress, identifier, password)
+		if !ok {
+			data := loginData(req)
+			data["Error"] = "Invalid username or password"
+			loginTmpl.Execute(w, data)
 			return
 		}
 
-		if !isRedirectURIAuthorized(redirectURI, client.RedirectURIs) {
-			http.Error(w, "invalid_redirect_uri", http.StatusBadRequest)
+		session, err := startSSOSession(w, r, user.ID)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
 			return
 		}
 
-		var user *models.User
-		var userErr error
+		req.consumePushedRequest()
+		completeAuthorization(w, r, client, req.authCode(user.ID, scope, session.AuthTime), req.State, req.Prompt)
+	}
+}
+
+// checkLoginRateLimit reports whether logins from the IP address or for the
+// identifier are currently blocked, with the message to show
+func checkLoginRateLimit(ipAddress, identifier string) (bool, string) {
+	blocked, message, err := sessionsAdapters.CheckRateLimit(ipAddress)
+	if err != nil {
+		log.Printf("Rate limiting error: %v", err)
+	} else if blocked {
+		return true, message
+	}
 
-		// Get user from the user adapters
-		if strings.Contains(identifier, "@") {
-			// If username contains '@', we assume it's an email
-			user, userErr = adapters.CurrentUserProvider.GetUserByEmail(identifier)
-		} else {
-			// Otherwise, we assume it's a username
-			user, userErr = adapters.CurrentUserProvider.GetUserByUsername(identifier)
+	if identifier != "" {
+		userKey := "user:" + identifier
+		blocked, message, err := sessionsAdapters.CheckRateLimit(userKey)
+		if err != nil {
+			log.Printf("Rate limiting error: %v", err)
+		} else if blocked {
+			return true, message
 		}
+	}
 
-		// Authentication failures handling with rate limiting
-		if userErr != nil || user == nil || !adapters.CurrentUserProvider.VerifyPassword(user.PasswordHash, password) {
-			attempts, err := sessionsAdapters.RecordFailedLoginAttempt(ipAddress)
-			if err != nil {
-				log.Printf("Error recording failed attempt for IP %s: %v", ipAddress, err)
-			} else {
-				log.Printf("Failed login attempt from IP %s: %d attempts", ipAddress, attempts)
-			}
+	return false, ""
+}
 
-			if identifier != "" {
-				userKey := "user:" + identifier
-				userAttempts, err := sessionsAdapters.RecordFailedLoginAttempt(userKey)
-				if err != nil {
-					log.Printf("Error recording failed attempt for user %s: %v", identifier, err)
-				} else {
-					log.Printf("Failed login attempt for user '%s': %d attempts", identifier, userAttempts)
-				}
-
-				if err := sessionsAdapters.CurrentLimiter.RecordUserIP(identifier, ipAddress); err != nil {
-					log.Printf("Error recording user-IP association on failed attempt: %v", err)
-				} else {
-					log.Printf("Associated user '%s' with IP '%s' on failed login attempt", identifier, ipAddress)
-				}
-			}
+// authenticateUser verifies the credentials with the user provider and
+// updates the rate limiting counters accordingly
+func authenticateUser(ipAddress, identifier, password string) (*models.User, bool) {
+	var user *models.User
+	var userErr error
 
-			data := loginData(clientID, redirectURI, codeChallenge, codeMethod, scope, state)
-			data["Error"] = "Invalid username or password"
-			loginTmpl.Execute(w, data)
-			return
+	// Get user from the user adapters
+	if strings.Contains(identifier, "@") {
+		// If username contains '@', we assume it's an email
+		user, userErr = adapters.CurrentUserProvider.GetUserByEmail(identifier)
+	} else {
+		// Otherwise, we assume it's a username
+		user, userErr = adapters.CurrentUserProvider.GetUserByUsername(identifier)
+	}
+
+	// Authentication failures handling with rate limiting
+	if userErr != nil || user == nil || !adapters.CurrentUserProvider.VerifyPassword(user.PasswordHash, password) {
+		recordFailedLogin(ipAddress, identifier)
+		return nil, false
+	}
+
+	// Successful authentication - reset rate limiting
+	if err := sessionsAdapters.ResetLoginAttempts(ipAddress); err != nil {
+		log.Printf("Error resetting rate limit for IP %s: %v", ipAddress, err)
+	}
+
+	if identifier != "" {
+		userKey := "user:" + identifier
+		if err := sessionsAdapters.ResetLoginAttempts(userKey); err != nil {
+			log.Printf("Error resetting rate limit for user %s: %v", identifier, err)
 		}
 
-		// Successful authentication - reset rate limiting
-		if err := sessionsAdapters.ResetLoginAttempts(ipAddress); err != nil {
-			log.Printf("Error resetting rate limit for IP %s: %v", ipAddress, err)
+		if err := sessionsAdapters.CurrentLimiter.RecordUserIP(identifier, ipAddress); err != nil {
+			log.Printf("Error recording user-IP association: %v", err)
+		} else {
+			log.Printf("Associated user '%s' with IP '%s' for rate limiting purposes", identifier, ipAddress)
 		}
+	}
 
-		if identifier != "" {
-			userKey := "user:" + identifier
-			if err := sessionsAdapters.ResetLoginAttempts(userKey); err != nil {
-				log.Printf("Error resetting rate limit for user %s: %v", identifier, err)
-			}
+	return user, true
+}
 
-			if err := sessionsAdapters.CurrentLimiter.RecordUserIP(identifier, ipAddress); err != nil {
-				log.Printf("Error recording user-IP association: %v", err)
-			} else {
-				log.Printf("Associated user '%s' with IP '%s' for rate limiting purposes", identifier, ipAddress)
-			}
-		}
+// recordFailedLogin counts a failed attempt against the IP address and, when
+// known, the identifier
+func recordFailedLogin(ipAddress, identifier string) {
+	attempts, err := sessionsAdapters.RecordFailedLoginAttempt(ipAddress)
+	if err != nil {
+		log.Printf("Error recording failed attempt for IP %s: %v", ipAddress, err)
+	} else {
+		log.Printf("Failed login attempt from IP %s: %d attempts", ipAddress, attempts)
+	}
 
-		code := uuid.NewString()
-		err = repositories.StoreAuthCode(&models.AuthCode{
-			Code:                code,
-			ClientID:            clientID,
-			RedirectURI:         redirectURI,
-			UserID:              user.ID,
-			CodeChallenge:       codeChallenge,
-			CodeChallengeMethod: codeMethod,
-			ExpiresAt:           time.Now().Add(10 * time.Minute),
-			Scope:               scope,
-		})
+	if identifier != "" {
+		userKey := "user:" + identifier
+		userAttempts, err := sessionsAdapters.RecordFailedLoginAttempt(userKey)
 		if err != nil {
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
+			log.Printf("Error recording failed attempt for user %s: %v", identifier, err)
+		} else {
+			log.Printf("Failed login attempt for user '%s': %d attempts", identifier, userAttempts)
 		}
 
-		log.Println("REDIRECT TO:", redirectURI+"?code="+code)
-
-		// Add state to redirect if present
-		redirectURL := redirectURI + "?code=" + code
-		if state != "" {
-			redirectURL += "&state=" + state
+		if err := sessionsAdapters.CurrentLimiter.RecordUserIP(identifier, ipAddress); err != nil {
+			log.Printf("Error recording user-IP association on failed attempt: %v", err)
+		} else {
+			log.Printf("Associated user '%s' with IP '%s' on failed login attempt", identifier, ipAddress)
 		}
+	}
+}
+
+// validateAuthorizeRequest checks the client and redirect URI of the request
+// and returns the scope the client may be granted. Errors are written to w
+func validateAuthorizeRequest(w http.ResponseWriter, req *authorizeRequest) (*models.Client, string, bool) {
+	client, err := repositories.GetClientByID(req.ClientID)
+	if err != nil {
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return nil, "", false
+	}
 
-		http.Redirect(w, r, redirectURL, http.StatusFound)
+	if !isRedirectURIAuthorized(req.RedirectURI, client.RedirectURIs) {
+		http.Error(w, "invalid_redirect_uri", http.StatusBadRequest)
+		return nil, "", false
 	}
+
+	if !req.Pushed && oauth.PushedAuthorizationRequired(client) {
+		http.Error(w, "invalid_request (pushed authorization request required)", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	if !checkClientPolicy(w, client, req.CodeChallenge, req.CodeChallengeMethod) {
+		return nil, "", false
+	}
+
+	// Drop the scopes the client is not allowed to request
+	scope, err := oauth.ResolveScopes(client, req.Scope)
+	if err == oauth.ErrInvalidScope {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return nil, "", false
+	}
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	return client, scope, true
+}
+
+// checkClientPolicy rejects authorization requests the client is not allowed
+// to make, or that lack the PKCE challenge required from public clients
+func checkClientPolicy(w http.ResponseWriter, client *models.Client, codeChallenge, codeChallengeMethod string) bool {
+	switch oauth.CheckAuthorizationRequest(client, codeChallenge, codeChallengeMethod) {
+	case nil:
+		return true
+	case oauth.ErrPKCERequired:
+		http.Error(w, "invalid_request (code_challenge with S256 required)", http.StatusBadRequest)
+	default:
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+	}
+	return false
+}
+
+// redirectError sends an authorization error back to the client
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, code, state string) {
+	params := url.Values{"error": {code}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, withQuery(redirectURI, params), http.StatusFound)
 }
 
-func loginData(clientID, redirectURI, codeChallenge, codeMethod, scope, state string) map[string]interface{} {
+// hasPrompt reports whether the space-separated prompt parameter contains value
+func hasPrompt(prompt, value string) bool {
+	for _, p := range strings.Fields(prompt) {
+		if p == value {
+			return true
+		}
+	}
+	return false
+}
+
+func loginData(req *authorizeRequest) map[string]interface{} {
 	logo := "/logo.png"
 	externalProviders, err := repositories.GetEnabledAuthProviders()
 	if err != nil {
@@ -187,14 +417,25 @@ func loginData(clientID, redirectURI, codeChallenge, codeMethod, scope, state st
 		externalProviders = []models.AuthProvider{}
 	}
 
+	// Unknown clients are reported once the form is submitted
+	var client *models.Client
+	if c, err := repositories.GetClientByID(req.ClientID); err == nil {
+		client = c
+	}
+
 	return map[string]interface{}{
-		"ClientID":            clientID,
-		"RedirectURI":         redirectURI,
-		"CodeChallenge":       codeChallenge,
-		"CodeChallengeMethod": codeMethod,
+		"Client":              client,
+		"ClientID":            req.ClientID,
+		"RedirectURI":         req.RedirectURI,
+		"CodeChallenge":       req.CodeChallenge,
+		"CodeChallengeMethod": req.CodeChallengeMethod,
 		"Logo":                logo,
-		"Scope":               scope,
-		"State":               state,
+		"Scope":               req.Scope,
+		"State":               req.State,
+		"Nonce":               req.Nonce,
+		"Prompt":              req.Prompt,
+		"RequestURI":          req.RequestURI,
+		"Request":             req.Request,
 		"ExternalProviders":   externalProviders,
 	}
 }
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"zenauth/internal/models"
+	"zenauth/internal/oauth"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+var consentTmpl = template.Must(template.ParseFiles("templates/consent.html.tmpl"))
+
+const (
+	authCodeLifetime       = 10 * time.Minute
+	consentRequestLifetime = 10 * time.Minute
+)
+
+// completeAuthorization issues the authorization code for an authenticated
+// user, showing the consent screen first when the scopes were not granted yet
+// or the client asked for it with prompt=consent
+func completeAuthorization(w http.ResponseWriter, r *http.Request, client *models.Client, authCode *models.AuthCode, state, prompt string) {
+	required, err := oauth.ConsentRequired(client, authCode.UserID, authCode.Scope)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if hasPrompt(prompt, "consent") {
+		required = true
+	}
+	if required && hasPrompt(prompt, "none") {
+		redirectError(w, r, authCode.RedirectURI, "consent_required", state)
+		return
+	}
+	if !required {
+		issueAuthorizationCode(w, r, authCode, state)
+		return
+	}
+
+	req := &models.ConsentRequest{
+		ID:                  uuid.NewString(),
+		ClientID:            authCode.ClientID,
+		RedirectURI:         authCode.RedirectURI,
+		UserID:              authCode.UserID,
+		CodeChallenge:       authCode.CodeChallenge,
+		CodeChallengeMethod: authCode.CodeChallengeMethod,
+		Scope:               authCode.Scope,
+		Nonce:               authCode.Nonce,
+		State:               state,
+		AuthTime:            authCode.AuthTime,
+		ExpiresAt:           time.Now().Add(consentRequestLifetime),
+	}
+	if err := repositories.StoreConsentRequest(req); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The consent screen must not be framed by another site
+	w.Header().Set("X-Frame-Options", "DENY")
+	consentTmpl.Execute(w, map[string]interface{}{
+		"ClientID":   client.ID,
+		"ClientName": client.Name,
+		"ConsentID":  req.ID,
+		"Scopes":     consentScopes(req.Scope),
+	})
+}
+
+type scopeItem struct {
+	Name        string
+	Description string
+}
+
+// consentScopes describes the requested scopes on the consent screen
+func consentScopes(scope string) []scopeItem {
+	scopes := []scopeItem{}
+	for _, name := range strings.Fields(scope) {
+		item := scopeItem{Name: name, Description: name}
+		if scope, err := repositories.GetScopeByName(name); err == nil && scope.Description != "" {
+			item.Description = scope.Description
+		}
+		scopes = append(scopes, item)
+	}
+	return scopes
+}
+
+// clientDisplayName returns the name shown to users for a client
+func clientDisplayName(client *models.Client) string {
+	if client.Name != "" {
+		return client.Name
+	}
+	return client.ID
+}
+
+// ConsentHandler handles the user's answer on the consent screen
+func ConsentHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := repositories.TakeConsentRequest(r.FormValue("consent_id"))
+	if err != nil || time.Now().After(req.ExpiresAt) {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// Only the signed-in user the request was made for may answer it
+	if session := currentSSOSession(r); session == nil || session.UserID != req.UserID {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	if r.FormValue("decision") != "approve" {
+		redirectError(w, r, req.RedirectURI, "access_denied", req.State)
+		return
+	}
+
+	if err := repositories.SaveConsent(req.UserID, req.ClientID, strings.Fields(req.Scope)); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	issueAuthorizationCode(w, r, &models.AuthCode{
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		UserID:              req.UserID,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		AuthTime:            req.AuthTime,
+	}, req.State)
+}
+
+// issueAuthorizationCode stores a new code and redirects back to the client
+func issueAuthorizationCode(w http.ResponseWriter, r *http.Request, authCode *models.AuthCode, state string) {
+	authCode.Code = uuid.NewString()
+	authCode.ExpiresAt = time.Now().Add(authCodeLifetime)
+	if err := repositories.StoreAuthCode(authCode); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	params := url.Values{"code": {authCode.Code}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	redirectURL := withQuery(authCode.RedirectURI, params)
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// withQuery appends params to a redirect URI that may already have a query
+func withQuery(uri string, params url.Values) string {
+	if strings.Contains(uri, "?") {
+		return uri + "&" + params.Encode()
+	}
+	return uri + "?" + params.Encode()
+}
+
+// UserConsentsHandler lists the grants of the user owning the access token
+func UserConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := bearerSubject(r)
+	if !ok {
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	consents, err := repositories.GetUserConsents(userID)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consents)
+}
+
+// UserConsentHandler lets the user owning the access token revoke a grant
+func UserConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := bearerSubject(r)
+	if !ok {
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	revokeConsent(w, userID, mux.Vars(r)["client_id"], "user")
+}
+
+// AdminUserConsentsHandler handles requests to the /admin/users/{id}/consents endpoint
+func AdminUserConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	consents, err := repositories.GetUserConsents(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to retrieve consents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consents)
+}
+
+// AdminUserConsentHandler handles requests to the /admin/users/{id}/consents/{client_id} endpoint
+func AdminUserConsentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	revokeConsent(w, vars["id"], vars["client_id"], "admin")
+}
+
+func revokeConsent(w http.ResponseWriter, userID, clientID, revokedBy string) {
+	err := oauth.RevokeConsent(userID, clientID, revokedBy)
+	if err == oauth.ErrConsentNotFound {
+		http.Error(w, "Consent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke consent", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bearerSubject returns the subject of a valid access token, bearer or
+// DPoP-bound
+func bearerSubject(r *http.Request) (string, bool) {
+	token, err := oauth.ValidateResourceRequest(r)
+	if err != nil {
+		return "", false
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	sub, _ := claims["sub"].(string)
+	return sub, sub != ""
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/oauth"
+	"zenauth/internal/repositories"
+)
+
+// Path of the page where users enter the code shown by their device
+const deviceVerificationPath = "/device"
+
+// DeviceAuthorizationHandler issues a device code and a user code (RFC 8628)
+func DeviceAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	client, err := oauth.IdentifyClient(r)
+	if err != nil {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	if !oauth.GrantAllowed(client, oauth.DeviceCodeGrantType) {
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return
+	}
+
+	device, err := oauth.StartDeviceAuthorization(client, r.FormValue("scope"))
+	if err == oauth.ErrInvalidScope {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	userCode := oauth.FormatUserCode(device.UserCode)
+	verificationURI := strings.TrimSuffix(config.App.Issuer, "/") + deviceVerificationPath
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":               device.DeviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		"expires_in":                int(time.Until(device.ExpiresAt).Seconds()),
+		"interval":                  device.Interval,
+	})
+}
+
+// DeviceVerificationHandler lets a user sign in, then approve or deny the
+// request of a device, identified by the user code it displays. The client
+// and the scopes it asked for are always shown before the user decides
+func DeviceVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	userCode := r.FormValue("user_code")
+	renderError := func(message string) {
+		data := deviceLoginData(userCode)
+		data["Error"] = message
+		loginTmpl.Execute(w, data)
+	}
+
+	ipAddress := getClientIP(r)
+	identifier := r.FormValue("identifier")
+	if blocked, message := checkLoginRateLimit(ipAddress, identifier); blocked {
+		renderError(message)
+		return
+	}
+
+	session := currentSSOSession(r)
+	if r.Method == http.MethodGet && (userCode == "" || session == nil) {
+		loginTmpl.Execute(w, deviceLoginData(userCode))
+		return
+	}
+
+	// Wrong user codes count as failed attempts, to stop codes being guessed
+	device, err := repositories.GetPendingDeviceCodeByUserCode(oauth.NormalizeUserCode(userCode))
+	if err != nil {
+		recordFailedLogin(ipAddress, "")
+		renderError("Invalid or expired code")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet:
+		// Signed-in users go straight to the confirmation
+
+	case r.FormValue("decision") != "":
+		// The decision must come from the signed-in user the confirmation
+		// was shown to
+		if session == nil || r.FormValue("confirmation") != deviceConfirmation(session, device) {
+			renderError("Your session has expired, please sign in again")
+			return
+		}
+		completeDeviceVerification(w, session.UserID, device, r.FormValue("decision") == "approve")
+		return
+
+	default:
+		user, ok := authenticateUser(ipAddress, identifier, r.FormValue("password"))
+		if !ok {
+			renderError("Invalid username or password")
+			return
+		}
+		if session, err = startSSOSession(w, r, user.ID); err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	client, err := repositories.GetClientByID(device.ClientID)
+	if err != nil {
+		renderError("Invalid or expired code")
+		return
+	}
+
+	// The confirmation must not be framed by another site
+	w.Header().Set("X-Frame-Options", "DENY")
+	consentTmpl.Execute(w, map[string]interface{}{
+		"ClientID":     client.ID,
+		"ClientName":   clientDisplayName(client),
+		"Scopes":       consentScopes(device.Scope),
+		"UserCode":     userCode,
+		"Confirmation": deviceConfirmation(session, device),
+	})
+}
+
+// completeDeviceVerification records the user's decision on a device request
+func completeDeviceVerification(w http.ResponseWriter, userID string, device *models.DeviceCode, approved bool) {
+	status, message := models.DeviceCodeDenied, "The device request was denied."
+	if approved {
+		status, message = models.DeviceCodeApproved, "Your device is now connected. You can return to it."
+	}
+
+	if err := repositories.CompleteDeviceCode(device.DeviceCode, status, userID, time.Now()); err != nil {
+		data := deviceLoginData("")
+		data["Error"] = "Invalid or expired code"
+		loginTmpl.Execute(w, data)
+		return
+	}
+
+	if approved {
+		if err := repositories.SaveConsent(userID, device.ClientID, strings.Fields(device.Scope)); err != nil {
+			log.Printf("Failed to record consent of user %s to client %s: %v", userID, device.ClientID, err)
+		}
+	}
+
+	data := deviceLoginData("")
+	data["Message"] = message
+	loginTmpl.Execute(w, data)
+}
+
+// deviceConfirmation binds the confirmation page to the SSO session it was
+// shown in and to the device request, so that it cannot be forged by another
+// site that knows the user code
+func deviceConfirmation(session *models.SSOSession, device *models.DeviceCode) string {
+	sum := sha256.Sum256([]byte(session.ID + ":" + device.DeviceCode))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// deviceLoginData builds the login template data for the device verification page
+func deviceLoginData(userCode string) map[string]interface{} {
+	data := loginData(&authorizeRequest{})
+	data["DeviceFlow"] = true
+	data["UserCode"] = userCode
+	return data
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"zenauth/config"
+	"zenauth/internal/oauth"
+)
+
+// Paths of the protocol endpoints, keyed by their discovery metadata name
+var endpoints map[string]string
+
+// RegisterEndpoints records the endpoints advertised in the discovery document
+func RegisterEndpoints(e map[string]string) {
+	endpoints = e
+}
+
+// DiscoveryHandler serves the OpenID Connect discovery document
+func DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	issuer := strings.TrimSuffix(config.App.Issuer, "/")
+
+	doc := map[string]interface{}{
+		"issuer":                                           issuer,
+		"response_types_supported":                         []string{"code"},
+		"response_modes_supported":                         []string{"query"},
+		"grant_types_supported":                            oauth.SupportedGrantTypes(flows),
+		"subject_types_supported":                          []string{"public"},
+		"id_token_signing_alg_values_supported":            []string{oauth.SigningAlgorithm()},
+		"code_challenge_methods_supported":                 []string{"S256", "plain"},
+		"scopes_supported":                                 oauth.SupportedScopes(),
+		"token_endpoint_auth_methods_supported":            oauth.AuthMethods,
+		"token_endpoint_auth_signing_alg_values_supported": oauth.AssertionSigningAlgorithms,
+		"backchannel_logout_supported":                     true,
+		"frontchannel_logout_supported":                    true,
+		"require_pushed_authorization_requests":            config.App.PushedAuthorization.Required,
+		"request_parameter_supported":                      true,
+		"request_uri_parameter_supported":                  true,
+		"require_request_uri_registration":                 true,
+		"request_object_signing_alg_values_supported":      oauth.AssertionSigningAlgorithms,
+		"dpop_signing_alg_values_supported":                oauth.DPoPSigningAlgorithms,
+		"tls_client_certificate_bound_access_tokens":       config.App.TLS.CertFile != "",
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+			"preferred_username", "email",
+		},
+	}
+
+	for name, path := range endpoints {
+		doc[name] = issuer + path
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(doc)
+}
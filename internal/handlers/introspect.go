@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"zenauth/internal/oauth"
+)
+
+// IntrospectHandler implements the RFC 7662 token introspection endpoint
+func IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := oauth.AuthenticateClient(r); err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="zenauth"`)
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(oauth.IntrospectToken(token, r.FormValue("token_type_hint")))
+}
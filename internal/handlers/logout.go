@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/oauth"
+	"zenauth/internal/repositories"
+)
+
+var logoutTmpl = template.Must(template.ParseFiles("templates/logout.html.tmpl"))
+
+// EndSessionHandler implements OpenID Connect RP-Initiated Logout: it ends
+// the browser's SSO session, after asking the user unless the id_token_hint
+// names the signed-in user, and sends the user back to the client when a
+// registered post_logout_redirect_uri is given
+func EndSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	clientID := r.FormValue("client_id")
+	postLogoutRedirectURI := r.FormValue("post_logout_redirect_uri")
+	state := r.FormValue("state")
+
+	// The ID token tells us which user and client are logging out
+	var hintSubject string
+	if hint := r.FormValue("id_token_hint"); hint != "" {
+		subject, audience, err := oauth.ParseIDTokenHint(hint)
+		if err != nil {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		if clientID != "" && clientID != audience {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		hintSubject, clientID = subject, audience
+	}
+
+	// The redirect target must be registered by an identified client
+	if postLogoutRedirectURI != "" {
+		if clientID == "" {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+
+		client, err := repositories.GetClientByID(clientID)
+		if err != nil {
+			http.Error(w, "unauthorized_client", http.StatusBadRequest)
+			return
+		}
+
+		if !isRedirectURIAuthorized(postLogoutRedirectURI, client.PostLogoutRedirectURIs) {
+			http.Error(w, "invalid_redirect_uri", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Only the browser's own session is ended. Unless the ID token names
+	// its user, the user confirms first, so that another site cannot sign
+	// them out
+	session := currentSSOSession(r)
+	confirmed := r.Method == http.MethodPost && session != nil &&
+		r.FormValue("confirmation") == logoutConfirmation(session)
+	if session != nil && session.UserID != hintSubject && !confirmed {
+		w.Header().Set("X-Frame-Options", "DENY")
+		logoutTmpl.Execute(w, map[string]interface{}{
+			"Confirm":               true,
+			"Confirmation":          logoutConfirmation(session),
+			"IDTokenHint":           r.FormValue("id_token_hint"),
+			"ClientID":              clientID,
+			"PostLogoutRedirectURI": postLogoutRedirectURI,
+			"State":                 state,
+		})
+		return
+	}
+
+	var userID string
+	if session != nil {
+		endSSOSession(w, r)
+		userID = session.UserID
+	}
+
+	// Tell the clients the user was signed in to
+	var frontchannelURIs []string
+	if userID != "" {
+		clients, err := repositories.GetUserSignedInClients(userID)
+		if err != nil {
+			log.Printf("Failed to list the clients of user %s: %v", userID, err)
+		}
+		oauth.NotifyBackchannelLogout(userID, clients)
+
+		for _, c := range clients {
+			if c.FrontchannelLogoutURI != "" {
+				frontchannelURIs = append(frontchannelURIs, c.FrontchannelLogoutURI)
+			}
+		}
+	}
+
+	if config.App.Logout.RevokeRefreshTokens && userID != "" && clientID != "" {
+		revoked, err := repositories.RevokeUserRefreshTokens(userID, clientID)
+		if err != nil {
+			log.Printf("Failed to revoke refresh tokens of user %s for client %s: %v", userID, clientID, err)
+		} else if revoked > 0 {
+			log.Printf("Revoked %d refresh tokens of user %s for client %s on logout", revoked, userID, clientID)
+		}
+	}
+
+	var redirectURL string
+	if postLogoutRedirectURI != "" {
+		redirectURL = postLogoutRedirectURI
+		if state != "" {
+			redirectURL = withQuery(postLogoutRedirectURI, url.Values{"state": {state}})
+		}
+	}
+
+	// Front-channel logout needs the logout page to load the clients' iframes
+	// before leaving
+	if redirectURL != "" && len(frontchannelURIs) == 0 {
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+		return
+	}
+
+	logoutTmpl.Execute(w, map[string]interface{}{
+		"FrontchannelLogoutURIs": frontchannelURIs,
+		"RedirectURL":            redirectURL,
+	})
+}
+
+// logoutConfirmation binds the logout confirmation page to the SSO session
+// it was shown in
+func logoutConfirmation(session *models.SSOSession) string {
+	sum := sha256.Sum256([]byte("logout:" + session.ID))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
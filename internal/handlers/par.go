@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"zenauth/internal/oauth"
+)
+
+// PushedAuthorizationHandler stores the authorization request a client
+// pushes over the back channel and returns the request_uri referencing it at
+// the authorization endpoint (RFC 9126)
+func PushedAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	client, err := oauth.AuthenticatePushingClient(r)
+	if err != nil {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	// A pushed request cannot itself reference another one
+	if r.PostForm.Get("request_uri") != "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if clientID := r.PostForm.Get("client_id"); clientID != "" && clientID != client.ID {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// The request is validated now, so that errors reach the client directly.
+	// A signed request object is verified again when the request_uri is used
+	params := r.PostForm
+	params.Set("client_id", client.ID)
+	if params.Get("request") != "" {
+		var ok bool
+		if params, ok = verifyRequestObject(w, params); !ok {
+			return
+		}
+	}
+
+	req := parseAuthorizeRequest(params)
+	req.Pushed = true
+	if _, _, ok := validateAuthorizeRequest(w, req); !ok {
+		return
+	}
+
+	pushed, err := oauth.PushAuthorizationRequest(client, r.PostForm)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_uri": pushed.RequestURI,
+		"expires_in":  int(time.Until(pushed.ExpiresAt).Seconds()),
+	})
+}
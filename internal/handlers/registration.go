@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/oauth"
+	"zenauth/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Path of the registration endpoint, under which each client gets its
+// configuration endpoint (RFC 7592)
+const registrationPath = "/register"
+
+// registrationResponse is the client information response of RFC 7591
+// section 3.2.1, also returned by the client configuration endpoint
+type registrationResponse struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   *int64 `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	oauth.ClientMetadata
+}
+
+// RegistrationHandler registers a client from its metadata (RFC 7591). When
+// initial access tokens are configured, one of them must be presented
+func RegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	if !validInitialAccessToken(bearerToken(r)) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	var metadata oauth.ClientMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		writeRegistrationError(w, &oauth.RegistrationError{Code: "invalid_client_metadata", Description: "malformed JSON body"})
+		return
+	}
+
+	client := &models.Client{ID: uuid.NewString()}
+	if err := metadata.ApplyTo(client); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	var secret string
+	if oauth.ClientNeedsSecret(client.TokenEndpointAuthMethod) {
+		var err error
+		if secret, err = oauth.SetClientSecret(client); err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	accessToken, err := oauth.NewRegistrationAccessToken(client)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := repositories.CreateClient(client); err != nil {
+		log.Printf("Failed to register client: %v", err)
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := newRegistrationResponse(client, secret)
+	resp.ClientIDIssuedAt = time.Now().Unix()
+	resp.RegistrationAccessToken = accessToken
+
+	writeRegistrationResponse(w, http.StatusCreated, resp)
+}
+
+// ClientConfigurationHandler reads, replaces or deletes the registration of
+// a client, authenticated by its registration access token (RFC 7592)
+func ClientConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	client, err := repositories.GetClientByID(mux.Vars(r)["client_id"])
+	if err != nil || !oauth.VerifyRegistrationAccessToken(client, bearerToken(r)) {
+		// Unknown clients are reported like bad tokens, so that client IDs cannot be probed
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeRegistrationResponse(w, http.StatusOK, newRegistrationResponse(client, ""))
+
+	case http.MethodPut:
+		var data struct {
+			ClientID string `json:"client_id"`
+			oauth.ClientMetadata
+		}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.ClientID != client.ID {
+			writeRegistrationError(w, &oauth.RegistrationError{Code: "invalid_client_metadata", Description: "client_id must match the registered client"})
+			return
+		}
+
+		if err := data.ClientMetadata.ApplyTo(client); err != nil {
+			writeRegistrationError(w, err)
+			return
+		}
+
+		// A client switching to a secret based method gets its first secret, and
+		// one switching to client_secret_jwt a secret it can sign assertions with
+		oauth.DiscardEncryptedClientSecrets(client)
+		var secret string
+		if oauth.ClientNeedsNewSecret(client) {
+			if secret, err = oauth.SetClientSecret(client); err != nil {
+				http.Error(w, "server_error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := repositories.UpdateClient(client); err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+		writeRegistrationResponse(w, http.StatusOK, newRegistrationResponse(client, secret))
+
+	case http.MethodDelete:
+		if err := repositories.DeleteClient(client.ID); err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func newRegistrationResponse(client *models.Client, secret string) registrationResponse {
+	resp := registrationResponse{
+		ClientID:              client.ID,
+		ClientSecret:          secret,
+		RegistrationClientURI: strings.TrimSuffix(config.App.Issuer, "/") + registrationPath + "/" + client.ID,
+		ClientMetadata:        oauth.ClientMetadataOf(client),
+	}
+
+	// client_secret_expires_at is required whenever a secret is issued, 0 meaning it never expires
+	if secret != "" {
+		var expiresAt int64
+		if client.SecretExpiresAt != nil {
+			expiresAt = client.SecretExpiresAt.Unix()
+		}
+		resp.ClientSecretExpiresAt = &expiresAt
+	}
+	return resp
+}
+
+func writeRegistrationResponse(w http.ResponseWriter, status int, resp registrationResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	var regErr *oauth.RegistrationError
+	if !errors.As(err, &regErr) {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(regErr)
+}
+
+// validInitialAccessToken accepts any request when no initial access token
+// is configured (open registration)
+func validInitialAccessToken(token string) bool {
+	if len(config.App.Registration.InitialAccessTokens) == 0 {
+		return true
+	}
+
+	valid := false
+	for _, t := range config.App.Registration.InitialAccessTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// bearerToken returns the token of a Bearer Authorization header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(auth, "Bearer ")
+}
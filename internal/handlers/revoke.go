@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"zenauth/internal/oauth"
+)
+
+// RevokeHandler implements the RFC 7009 token revocation endpoint
+func RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	client, err := oauth.AuthenticateClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="zenauth"`)
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	err = oauth.RevokeToken(token, r.FormValue("token_type_hint"), client.ID)
+	switch {
+	case errors.Is(err, oauth.ErrTokenNotOwned):
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return
+	case errors.Is(err, oauth.ErrUnsupportedTokenType):
+		http.Error(w, "unsupported_token_type", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Token revocation error for client %s: %v", client.ID, err)
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"zenauth/internal/repositories"
+
+	"github.com/gorilla/mux"
+)
+
+// scopeNamePattern matches the scope-token syntax of RFC 6749 section 3.3
+var scopeNamePattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+
+// AdminScopesHandler handles requests to the /admin/scopes endpoint
+func AdminScopesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listScopes(w, r)
+	case http.MethodPost:
+		createScope(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminScopeHandler handles requests to the /admin/scopes/{name} endpoint
+func AdminScopeHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	switch r.Method {
+	case http.MethodGet:
+		getScope(w, r, name)
+	case http.MethodPut:
+		updateScope(w, r, name)
+	case http.MethodDelete:
+		deleteScope(w, r, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listScopes(w http.ResponseWriter, r *http.Request) {
+	scopes, err := repositories.GetAllScopes()
+	if err != nil {
+		http.Error(w, "Failed to retrieve scopes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scopes)
+}
+
+func createScope(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !scopeNamePattern.MatchString(data.Name) {
+		http.Error(w, "Scope name is required and may not contain spaces, quotes or backslashes", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := repositories.CreateScope(data.Name, data.Description)
+	if err != nil {
+		http.Error(w, "Failed to create scope: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scope)
+}
+
+func getScope(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := repositories.GetScopeByName(name)
+	if err != nil {
+		http.Error(w, "Scope not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scope)
+}
+
+func updateScope(w http.ResponseWriter, r *http.Request, name string) {
+	var data struct {
+		Description string `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := repositories.GetScopeByName(name); err != nil {
+		http.Error(w, "Scope not found", http.StatusNotFound)
+		return
+	}
+
+	if err := repositories.UpdateScope(name, data.Description); err != nil {
+		http.Error(w, "Failed to update scope", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Scope updated successfully",
+	})
+}
+
+func deleteScope(w http.ResponseWriter, r *http.Request, name string) {
+	if err := repositories.DeleteScope(name); err != nil {
+		http.Error(w, "Failed to delete scope", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkScopesExist rejects client scope lists naming unregistered scopes
+func checkScopesExist(names []string) error {
+	for _, name := range names {
+		if _, err := repositories.GetScopeByName(name); err != nil {
+			return fmt.Errorf("unknown scope: %s", name)
+		}
+	}
+	return nil
+}
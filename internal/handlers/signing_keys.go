@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"zenauth/internal/oauth"
+)
+
+// AdminSigningKeysHandler lists the token signing keys and their state
+func AdminSigningKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := oauth.ListSigningKeys()
+	if errors.Is(err, oauth.ErrKeyRotationUnavailable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve signing keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// AdminRotateSigningKeyHandler creates a new signing key. With "immediate"
+// the key starts signing right away instead of waiting for the publish delay
+func AdminRotateSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Immediate bool `json:"immediate"`
+	}
+
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, err := oauth.RotateSigningKey(data.Immediate)
+	if errors.Is(err, oauth.ErrKeyRotationUnavailable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rotate signing key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// currentSSOSession returns the browser's SSO session, or nil if it has none
+func currentSSOSession(r *http.Request) *models.SSOSession {
+	cookie, err := r.Cookie(config.App.SSOSession.CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	session, err := repositories.GetSSOSession(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// startSSOSession signs the user in at the authorization server, replacing
+// any session the browser already had
+func startSSOSession(w http.ResponseWriter, r *http.Request, userID string) (*models.SSOSession, error) {
+	if previous := currentSSOSession(r); previous != nil {
+		if err := repositories.DeleteSSOSession(previous.ID); err != nil {
+			log.Printf("Failed to delete SSO session: %v", err)
+		}
+	}
+
+	now := time.Now()
+	session := &models.SSOSession{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		AuthTime:  now,
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.App.SSOSession.Lifetime),
+	}
+	if err := repositories.CreateSSOSession(session); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.App.SSOSession.CookieName,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   config.App.SSOSession.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return session, nil
+}
+
+// endSSOSession deletes the browser's SSO session and its cookie
+func endSSOSession(w http.ResponseWriter, r *http.Request) *models.SSOSession {
+	session := currentSSOSession(r)
+	if session != nil {
+		if err := repositories.DeleteSSOSession(session.ID); err != nil {
+			log.Printf("Failed to delete SSO session: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.App.SSOSession.CookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   config.App.SSOSession.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return session
+}
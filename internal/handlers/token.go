@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"zenauth/internal/oauth"
 )
@@ -18,11 +19,40 @@ func TokenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	grantType := r.FormValue("grant_type")
+
+	var handler oauth.OAuthFlow
 	for _, flow := range flows {
 		if flow.Supports(grantType) {
-			flow.HandleTokenRequest(w, r)
-			return
+			handler = flow
+			break
 		}
 	}
-	http.Error(w, "unsupported_grant_type", http.StatusBadRequest)
+	if handler == nil {
+		http.Error(w, "unsupported_grant_type", http.StatusBadRequest)
+		return
+	}
+
+	// A DPoP proof binds the issued tokens to its key
+	r, err := oauth.WithDPoPProof(r)
+	if err != nil {
+		http.Error(w, "invalid_dpop_proof", http.StatusBadRequest)
+		return
+	}
+
+	// The client is resolved once, and may only use its registered grant types
+	r, client, err := oauth.WithTokenClient(r)
+	if errors.Is(err, oauth.ErrInvalidGrant) {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+	if !oauth.GrantAllowed(client, grantType) {
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return
+	}
+
+	handler.HandleTokenRequest(w, r)
 }
@@ -10,15 +10,14 @@ import (
 )
 
 func UserInfoHandler(w http.ResponseWriter, r *http.Request) {
-	auth := r.Header.Get("Authorization")
-	if !strings.HasPrefix(auth, "Bearer ") {
-		http.Error(w, "invalid_token", http.StatusUnauthorized)
+	// Bearer tokens and DPoP-bound tokens with their proof
+	token, err := oauth.ValidateResourceRequest(r)
+	if err == oauth.ErrInvalidDPoPProof {
+		w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof", algs="`+strings.Join(oauth.DPoPSigningAlgorithms, " ")+`"`)
+		http.Error(w, "invalid_dpop_proof", http.StatusUnauthorized)
 		return
 	}
-
-	tokenStr := strings.TrimPrefix(auth, "Bearer ")
-	token, err := oauth.ValidateAccessToken(tokenStr)
-	if err != nil || !token.Valid {
+	if err != nil {
 		http.Error(w, "invalid_token", http.StatusUnauthorized)
 		return
 	}
@@ -29,11 +28,18 @@ func UserInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sub, _ := claims["sub"].(string)
+	scope, _ := claims["scope"].(string)
+
+	// Tokens issued to clients (client_credentials) have no user behind them
+	resp := map[string]interface{}{
+		"sub": sub,
+	}
+	if user, err := oauth.LookupUser(sub); err == nil {
+		resp = oauth.UserClaims(user, scope)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"sub":   claims["sub"],
-		"scope": claims["scope"],
-	})
-
+	json.NewEncoder(w).Encode(resp)
 }
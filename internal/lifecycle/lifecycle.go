@@ -0,0 +1,124 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Component is a subsystem owned by the application: a resource opened by
+// Start and released by Stop, and/or long-running work done by Run
+type Component struct {
+	Name string
+
+	// Start opens the component, components are started in order
+	Start func() error
+
+	// Run does the work of the component until ctx is cancelled or Stop is
+	// called. A non-nil error shuts the application down
+	Run func(ctx context.Context) error
+
+	// Stop releases the component, components are stopped in reverse order
+	// within the shutdown deadline
+	Stop func(ctx context.Context) error
+}
+
+// Lifecycle starts the components of the application in order, and stops them
+// in reverse order on SIGINT, SIGTERM or when one of them fails
+type Lifecycle struct {
+	components []*running
+	failed     chan error
+}
+
+type running struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{failed: make(chan error, 1)}
+}
+
+// Add appends a component, started after the ones already added
+func (l *Lifecycle) Add(c Component) {
+	l.components = append(l.components, &running{Component: c})
+}
+
+// Run starts every component and blocks until the application is shut down.
+// The components have shutdownTimeout to stop once a signal is received
+func (l *Lifecycle) Run(shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	started, err := l.start()
+	if err == nil {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Shutting down")
+		case err = <-l.failed:
+			log.Printf("❌ %v, shutting down", err)
+		}
+	}
+
+	// A second signal interrupts the process without waiting any further
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return errors.Join(err, l.stop(shutdownCtx, started))
+}
+
+// start starts the components in order, and returns how many were started
+func (l *Lifecycle) start() (int, error) {
+	for i, c := range l.components {
+		if c.Start != nil {
+			if err := c.Start(); err != nil {
+				return i, fmt.Errorf("failed to start %s: %w", c.Name, err)
+			}
+		}
+
+		if c.Run != nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			c.cancel, c.done = cancel, make(chan struct{})
+			go func(c *running) {
+				defer close(c.done)
+				if err := c.Run(ctx); err != nil {
+					select {
+					case l.failed <- fmt.Errorf("%s failed: %w", c.Name, err):
+					default:
+					}
+				}
+			}(c)
+		}
+	}
+	return len(l.components), nil
+}
+
+// stop stops the first n components in reverse order
+func (l *Lifecycle) stop(ctx context.Context, n int) error {
+	var errs []error
+	for i := n - 1; i >= 0; i-- {
+		c := l.components[i]
+
+		if c.Stop != nil {
+			if err := c.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, err))
+			}
+		}
+
+		if c.Run != nil {
+			c.cancel()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("%s did not stop in time", c.Name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects what the components did, in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// component starts and stops cleanly, recording both
+func (r *recorder) component(name string) Component {
+	return Component{
+		Name:  name,
+		Start: func() error { r.add("start " + name); return nil },
+		Stop:  func(context.Context) error { r.add("stop " + name); return nil },
+	}
+}
+
+// worker runs until it is cancelled
+func (r *recorder) worker(name string) Component {
+	return Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			r.add("done " + name)
+			return nil
+		},
+	}
+}
+
+// failing runs and fails right away, which shuts the application down
+func (r *recorder) failing(name string) Component {
+	return Component{
+		Name: name,
+		Run:  func(context.Context) error { return errors.New("boom") },
+	}
+}
+
+func TestRun(t *testing.T) {
+	// stuck is closed once the test is over, releasing the component that
+	// ignores its context
+	stuck := make(chan struct{})
+	t.Cleanup(func() { close(stuck) })
+
+	tests := []struct {
+		name       string
+		components func(r *recorder) []Component
+		wantErrs   []string
+		wantEvents []string
+	}{
+		{
+			name: "failing component stops the others in reverse order",
+			components: func(r *recorder) []Component {
+				return []Component{r.component("database"), r.worker("sweeper"), r.component("server"), r.failing("job")}
+			},
+			wantErrs:   []string{"job failed: boom"},
+			wantEvents: []string{"start database", "start server", "stop server", "done sweeper", "stop database"},
+		},
+		{
+			name: "start failure stops the components already started",
+			components: func(r *recorder) []Component {
+				return []Component{
+					r.component("database"),
+					{Name: "keys", Start: func() error { return errors.New("no key") }, Stop: func(context.Context) error {
+						r.add("stop keys")
+						return nil
+					}},
+					r.component("server"),
+				}
+			},
+			wantErrs:   []string{"failed to start keys: no key"},
+			wantEvents: []string{"start database", "stop database"},
+		},
+		{
+			name: "stop errors are reported",
+			components: func(r *recorder) []Component {
+				return []Component{
+					r.component("database"),
+					{Name: "cache", Stop: func(context.Context) error { return errors.New("close failed") }},
+					r.failing("job"),
+				}
+			},
+			wantErrs:   []string{"job failed: boom", "failed to stop cache: close failed"},
+			wantEvents: []string{"start database", "stop database"},
+		},
+		{
+			name: "components ignoring their context are given up on",
+			components: func(r *recorder) []Component {
+				return []Component{
+					r.component("database"),
+					{Name: "stuck", Run: func(context.Context) error { <-stuck; return nil }},
+					r.failing("job"),
+				}
+			},
+			wantErrs:   []string{"job failed: boom", "stuck did not stop in time"},
+			wantEvents: []string{"start database", "stop database"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			app := New()
+			for _, c := range tt.components(r) {
+				app.Add(c)
+			}
+
+			err := app.Run(50 * time.Millisecond)
+			if err == nil {
+				t.Fatal("Run() error = nil, want an error")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Run() error = %q, want it to contain %q", err, want)
+				}
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if !reflect.DeepEqual(r.events, tt.wantEvents) {
+				t.Errorf("events = %q, want %q", r.events, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+const (
+	AuditRefreshTokenReuse = "refresh_token_reuse"
+	AuditConsentRevoked    = "consent_revoked"
+)
+
+type AuditEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	ClientID  string    `json:"client_id,omitempty"`
+	UserID    *string   `json:"user_id,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -11,4 +11,6 @@ type AuthCode struct {
 	CodeChallengeMethod string
 	ExpiresAt           time.Time
 	Scope               string
+	Nonce               string
+	AuthTime            time.Time
 }
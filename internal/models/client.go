@@ -1,8 +1,101 @@
 package models
 
+import "time"
+
+type ClientType string
+
+const (
+	// Confidential clients can keep a credential secret
+	ClientConfidential ClientType = "confidential"
+	// Public clients (browser and native apps) cannot, and must use PKCE
+	ClientPublic ClientType = "public"
+)
+
 type Client struct {
 	ID           string
-	Secret       string
+	Type         ClientType
 	Name         string
 	RedirectURIs []string
+
+	// Shown on the login page
+	LogoURI   string
+	ClientURI string
+	PolicyURI string
+	TosURI    string
+
+	// Access token lifetime in seconds, 0 uses the server default
+	AccessTokenLifetime int
+
+	// bcrypt hashes of the client secrets. During a rotation the previous
+	// secret stays valid until PreviousSecretExpiresAt; nil expiries never expire
+	SecretHash              string `json:"-"`
+	SecretExpiresAt         *time.Time
+	PreviousSecretHash      string `json:"-"`
+	PreviousSecretExpiresAt *time.Time
+
+	// client_secret_jwt assertions are HMAC signed with the secret itself, so
+	// clients registered with that method also keep their secrets encrypted
+	EncryptedSecret         []byte `json:"-"`
+	PreviousEncryptedSecret []byte `json:"-"`
+
+	// Refresh token policy in seconds, 0 uses the server defaults
+	RefreshTokenLifetime    int
+	RefreshTokenIdleTimeout int
+
+	// Scopes the client may request, empty allows every registered scope
+	AllowedScopes []string
+
+	// First-party clients are not shown the consent screen
+	ConsentExempt bool
+
+	// Where the end_session endpoint may send the user after logout
+	PostLogoutRedirectURIs []string
+
+	// Logout notifications: logout tokens are POSTed to the back-channel URI,
+	// the front-channel URI is loaded in an iframe of the logout page
+	BackchannelLogoutURI  string
+	FrontchannelLogoutURI string
+
+	// Audiences the client may request when exchanging tokens (RFC 8693),
+	// empty disables token exchange for the client
+	TokenExchangeAudiences []string
+
+	// Authentication method required at the token endpoint, empty accepts
+	// any method based on the client secret
+	TokenEndpointAuthMethod string
+
+	// Public keys verifying the client's JWT assertions (private_key_jwt and
+	// the jwt-bearer grant), as a JSON Web Key Set or PEM encoded keys
+	JWKS string
+
+	// The jwt-bearer grant may assert users, not only the client itself.
+	// Only admins can enable it, independently of ConsentExempt
+	JWTBearerUserAssertions bool
+
+	// Grant types the client may use, empty allows every grant
+	GrantTypes []string
+
+	// Authorization requests must be pushed to the PAR endpoint (RFC 9126)
+	RequirePushedAuthorizationRequests bool
+
+	// Certificates accepted for mutual-TLS client authentication (RFC 8705):
+	// the subject DN of a CA-issued certificate for tls_client_auth, or the
+	// SHA-256 thumbprints (base64url, x5t#S256) of self-signed certificates
+	TLSClientAuthSubjectDN          string
+	TLSClientCertificateThumbprints []string
+
+	// Access tokens must be bound to a DPoP key (RFC 9449)
+	DPoPBoundAccessTokens bool
+
+	// Where the client publishes its signed request objects (RFC 9101),
+	// the only request_uri values fetched for it
+	RequestURIs []string
+
+	// SHA-256 of the token managing a dynamically registered client (RFC 7592),
+	// empty for clients created by an admin
+	RegistrationAccessTokenHash string `json:"-"`
+}
+
+func (c *Client) IsPublic() bool {
+	return c.Type == ClientPublic
 }
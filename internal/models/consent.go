@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Consent records the scopes a user granted to a client
+type Consent struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsentRequest is an authenticated authorization request waiting for the
+// user to approve or deny the requested scopes
+type ConsentRequest struct {
+	ID                  string
+	ClientID            string
+	RedirectURI         string
+	UserID              string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Scope               string
+	Nonce               string
+	State               string
+	AuthTime            time.Time
+	ExpiresAt           time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type DeviceCodeStatus string
+
+const (
+	DeviceCodePending  DeviceCodeStatus = "pending"
+	DeviceCodeApproved DeviceCodeStatus = "approved"
+	DeviceCodeDenied   DeviceCodeStatus = "denied"
+)
+
+// DeviceCode is a device authorization request (RFC 8628)
+type DeviceCode struct {
+	DeviceCode   string
+	UserCode     string // Normalized: upper case, without separator
+	ClientID     string
+	Scope        string
+	Status       DeviceCodeStatus
+	UserID       *string // Set once a user approved the request
+	AuthTime     *time.Time
+	Interval     int // Minimum seconds between two polls
+	LastPolledAt *time.Time
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PushedAuthorizationRequest holds the authorization request parameters a
+// client pushed to the PAR endpoint (RFC 9126), until the user agent brings
+// its request_uri to the authorization endpoint
+type PushedAuthorizationRequest struct {
+	RequestURI string
+	ClientID   string
+	Parameters string // Form encoded
+	ExpiresAt  time.Time
+}
@@ -0,0 +1,7 @@
+package models
+
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type SigningKeyState string
+
+const (
+	SigningKeyPending SigningKeyState = "pending"
+	SigningKeyActive  SigningKeyState = "active"
+	SigningKeyRetired SigningKeyState = "retired"
+)
+
+type SigningKey struct {
+	ID          string          `json:"kid"`
+	Algorithm   string          `json:"alg"`
+	PrivateKey  []byte          `json:"-"` // Encrypted PEM, never exposed
+	State       SigningKeyState `json:"state"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ActivatedAt *time.Time      `json:"activated_at,omitempty"`
+	RetiredAt   *time.Time      `json:"retired_at,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"` // Retired keys stop validating tokens after this
+}
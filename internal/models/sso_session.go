@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SSOSession is a browser session at the authorization server, shared by
+// every client the user signs in to
+type SSOSession struct {
+	ID        string
+	UserID    string
+	AuthTime  time.Time // When the user last entered credentials
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Token struct {
 	AccessToken  string
 	RefreshToken string
@@ -9,8 +11,22 @@ type Token struct {
 }
 
 type RefreshToken struct {
-	Token    string
-	ClientID string
-	UserID   *string
-	IssuedAt string
+	Token      string
+	ClientID   string
+	UserID     *string
+	FamilyID   string // Shared by every token obtained by rotation from the same grant
+	Scope      string // Scope of the original grant, a refresh may only narrow it
+	IssuedAt   time.Time
+	ExpiresAt  *time.Time // Absolute expiry of the family, nil when unlimited
+	LastUsedAt *time.Time
+	RotatedAt  *time.Time
+	DPoPJKT    string // Thumbprint of the DPoP key the token is bound to, empty when unbound
+}
+
+// LastActivity returns when the token was last used, or issued if never used
+func (rt *RefreshToken) LastActivity() time.Time {
+	if rt.LastUsedAt != nil {
+		return *rt.LastUsedAt
+	}
+	return rt.IssuedAt
 }
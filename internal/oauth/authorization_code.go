@@ -40,9 +40,13 @@ func (f *AuthorizationCodeFlow) HandleTokenRequest(w http.ResponseWriter, r *htt
 		return
 	}
 
-	client, err := repositories.GetClientByID(authCode.ClientID)
-	if err != nil {
-		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+	// The code may only be redeemed by the client it was issued to
+	client := tokenClient(r)
+	if authCode.ClientID != client.ID {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if !checkDPoPPolicy(w, r, client) {
 		return
 	}
 
@@ -57,6 +61,12 @@ func (f *AuthorizationCodeFlow) HandleTokenRequest(w http.ResponseWriter, r *htt
 		return
 	}
 
+	// Codes of public clients must be bound to a PKCE challenge
+	if client.IsPublic() && authCode.CodeChallenge == "" {
+		http.Error(w, "invalid_grant (pkce)", http.StatusBadRequest)
+		return
+	}
+
 	// Verify PKCE
 	if err := verifyPKCE(authCode.CodeChallenge, authCode.CodeChallengeMethod, codeVerifier); err != nil {
 		http.Error(w, "invalid_grant (pkce)", http.StatusBadRequest)
@@ -66,25 +76,12 @@ func (f *AuthorizationCodeFlow) HandleTokenRequest(w http.ResponseWriter, r *htt
 	// Delete the code after use (security)
 	_ = repositories.DeleteAuthCode(code)
 
-	// Generate access_token
-	accessToken, err := GenerateAccessToken(authCode.UserID, authCode.Scope)
+	token, err := userTokenResponse(client, authCode.UserID, authCode.Scope, authCode.Nonce, authCode.AuthTime, tokenBinding(r))
 	if err != nil {
 		http.Error(w, "server_error", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate refresh_token
-	refreshToken := generateRandomToken()
-	_ = repositories.StoreRefreshToken(refreshToken, authCode.ClientID, &authCode.UserID)
-
-	// Response
-	token := map[string]interface{}{
-		"access_token":  accessToken,
-		"token_type":    "bearer",
-		"expires_in":    3600,
-		"refresh_token": refreshToken,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(token)
 }
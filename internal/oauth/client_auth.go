@@ -0,0 +1,315 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidClient = errors.New("invalid_client")
+
+// Client authentication methods at the token endpoint
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodClientSecretJWT   = "client_secret_jwt"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodNone              = "none"
+
+	// Mutual-TLS client authentication (RFC 8705)
+	AuthMethodTLSClientAuth           = "tls_client_auth"
+	AuthMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// ClientAssertionType is the client_assertion_type of JWT client assertions (RFC 7523)
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// AuthMethods lists the client authentication methods, in the order they are advertised
+var AuthMethods = []string{
+	AuthMethodClientSecretBasic,
+	AuthMethodClientSecretPost,
+	AuthMethodClientSecretJWT,
+	AuthMethodPrivateKeyJWT,
+	AuthMethodTLSClientAuth,
+	AuthMethodSelfSignedTLSClientAuth,
+	AuthMethodNone,
+}
+
+// AssertionSigningAlgorithms lists the algorithms accepted for JWT client
+// assertions: HMAC with the client secret (client_secret_jwt), or a
+// signature with one of the client's registered keys
+var AssertionSigningAlgorithms = append([]string{"HS256", "HS384", "HS512"}, asymmetricSigningAlgorithms...)
+
+// asymmetricSigningAlgorithms lists the public key algorithms accepted for
+// JWTs signed by clients
+var asymmetricSigningAlgorithms = []string{"RS256", "PS256", "ES256", AlgEdDSA}
+
+// recordAssertionJTI remembers the jti of an assertion until it expires, and
+// reports whether it was not seen before
+var recordAssertionJTI = repositories.RecordAssertionJTI
+
+// maxAssertionLifetime bounds how far in the future an assertion may expire,
+// which also bounds how long its jti has to be remembered
+const maxAssertionLifetime = time.Hour
+
+// AuthenticateClient verifies the client credentials sent with the request:
+// HTTP Basic, client_secret in the form body, a JWT client assertion
+// signed with the client secret or one of the client's registered keys, or the TLS client
+// certificate of a client sending only its client_id.
+// A client registered with a token_endpoint_auth_method must use it
+func AuthenticateClient(r *http.Request) (*models.Client, error) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	post := r.PostFormValue("client_secret") != ""
+	assertion := r.PostFormValue("client_assertion_type") != ""
+
+	// Clients must not use more than one authentication method per request
+	used := 0
+	for _, present := range []bool{basic, post, assertion} {
+		if present {
+			used++
+		}
+	}
+	if used == 0 && clientCertificate(r) != nil {
+		client, _, err := authenticateClientCertificate(r)
+		if err != nil {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+	if used != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	var client *models.Client
+	var method string
+	var err error
+	switch {
+	case assertion:
+		client, method, err = authenticateClientAssertion(r)
+	case basic:
+		method = AuthMethodClientSecretBasic
+		client, err = authenticateClientSecret(clientID, clientSecret)
+	case post:
+		method = AuthMethodClientSecretPost
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		client, err = authenticateClientSecret(clientID, clientSecret)
+	}
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	if !clientAuthMethodAllowed(client, method) {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// IdentifyClient authenticates the client when it sent credentials, and
+// otherwise identifies a public client by its client_id parameter.
+// Confidential clients are always authenticated
+func IdentifyClient(r *http.Request) (*models.Client, error) {
+	if hasClientCredentials(r) {
+		return AuthenticateClient(r)
+	}
+
+	clientID := r.FormValue("client_id")
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := repositories.GetClientByID(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	// Only public clients are identified by their client_id alone. This
+	// includes clients authenticating with their TLS certificate
+	if !client.IsPublic() {
+		return AuthenticateClient(r)
+	}
+	return client, nil
+}
+
+// ValidateClientAuthSettings checks the authentication method and keys
+// registered for a client: private_key_jwt needs at least one public key
+func ValidateClientAuthSettings(method, jwks string) error {
+	keys, err := ParseClientKeys(jwks)
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case "", AuthMethodNone:
+	case AuthMethodPrivateKeyJWT:
+		if len(keys) == 0 {
+			return fmt.Errorf("%s requires registered public keys", method)
+		}
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodClientSecretJWT:
+	case AuthMethodTLSClientAuth, AuthMethodSelfSignedTLSClientAuth:
+	default:
+		return fmt.Errorf("unsupported token endpoint auth method: %s", method)
+	}
+	return nil
+}
+
+func hasClientCredentials(r *http.Request) bool {
+	if _, _, ok := r.BasicAuth(); ok {
+		return true
+	}
+	return r.PostFormValue("client_secret") != "" || r.PostFormValue("client_assertion_type") != ""
+}
+
+func authenticateClientSecret(clientID, clientSecret string) (*models.Client, error) {
+	client, err := repositories.GetClientByID(clientID)
+	if err != nil || !VerifyClientSecret(client, clientSecret) {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// clientAuthMethodAllowed checks the method against the one registered for
+// the client. Clients without one may use any method except none
+func clientAuthMethodAllowed(client *models.Client, method string) bool {
+	if client.TokenEndpointAuthMethod == "" {
+		return method != AuthMethodNone
+	}
+	return client.TokenEndpointAuthMethod == method
+}
+
+// authenticateClientAssertion verifies a client_secret_jwt or private_key_jwt
+// assertion. Its iss and sub must both be the client ID (RFC 7523 section 3)
+func authenticateClientAssertion(r *http.Request) (*models.Client, string, error) {
+	if r.PostFormValue("client_assertion_type") != ClientAssertionType {
+		return nil, "", ErrInvalidClient
+	}
+	assertion := r.PostFormValue("client_assertion")
+
+	unverified, _, err := new(jwt.Parser).ParseUnverified(assertion, jwt.MapClaims{})
+	if err != nil {
+		return nil, "", ErrInvalidClient
+	}
+	claims := unverified.Claims.(jwt.MapClaims)
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if issuer == "" || issuer != subject {
+		return nil, "", ErrInvalidClient
+	}
+	if clientID := r.PostFormValue("client_id"); clientID != "" && clientID != issuer {
+		return nil, "", ErrInvalidClient
+	}
+
+	client, err := repositories.GetClientByID(issuer)
+	if err != nil {
+		return nil, "", ErrInvalidClient
+	}
+
+	if _, err := verifyClientJWT(client, assertion, r); err != nil {
+		return nil, "", ErrInvalidClient
+	}
+
+	if _, ok := unverified.Method.(*jwt.SigningMethodHMAC); ok {
+		return client, AuthMethodClientSecretJWT, nil
+	}
+	return client, AuthMethodPrivateKeyJWT, nil
+}
+
+// verifyClientJWT verifies a JWT issued by a client: an HMAC signature with
+// the client secret or a signature with one of its registered keys, the
+// issuer, an audience naming this server, a bounded expiry, and a jti that
+// was not used before
+func verifyClientJWT(client *models.Client, tokenString string, r *http.Request) (jwt.MapClaims, error) {
+	claims, err := parseClientSignedJWT(client, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !claims.VerifyIssuer(client.ID, true) ||
+		!claims.VerifyExpiresAt(now.Unix(), true) ||
+		!verifyAssertionAudience(claims, r) {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	exp := time.Unix(int64(claims["exp"].(float64)), 0)
+	if exp.After(now.Add(maxAssertionLifetime)) {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	fresh, err := recordAssertionJTI(client.ID, jti, exp)
+	if err != nil || !fresh {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return claims, nil
+}
+
+// parseClientSignedJWT verifies the signature of a JWT with the secret of a
+// client_secret_jwt client or one of the registered keys of the client, and
+// its time based claims
+func parseClientSignedJWT(client *models.Client, tokenString string) (jwt.MapClaims, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	if !algorithmSupported(AssertionSigningAlgorithms, unverified.Method.Alg()) {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	var keys []interface{}
+	if _, ok := unverified.Method.(*jwt.SigningMethodHMAC); ok {
+		for _, secret := range clientSecretKeys(client) {
+			keys = append(keys, secret)
+		}
+	} else {
+		kid, _ := unverified.Header["kid"].(string)
+		for _, key := range clientVerificationKeys(client, unverified.Method.Alg(), kid) {
+			keys = append(keys, key)
+		}
+	}
+
+	// Without a matching kid, each candidate key is tried in turn
+	var token *jwt.Token
+	err = jwt.ErrSignatureInvalid
+	for _, key := range keys {
+		token, err = jwt.Parse(tokenString, func(*jwt.Token) (interface{}, error) { return key, nil })
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims.(jwt.MapClaims), nil
+}
+
+// verifyAssertionAudience accepts the issuer identifier, the token endpoint
+// and the URL of the endpoint receiving the assertion as audience
+func verifyAssertionAudience(claims jwt.MapClaims, r *http.Request) bool {
+	issuer := strings.TrimSuffix(config.App.Issuer, "/")
+	for _, aud := range []string{issuer, issuer + "/", issuer + "/token", issuer + r.URL.Path} {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func algorithmSupported(algorithms []string, alg string) bool {
+	for _, supported := range algorithms {
+		if alg == supported {
+			return true
+		}
+	}
+	return false
+}
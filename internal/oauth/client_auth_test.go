@@ -0,0 +1,133 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+func TestVerifyClientJWT(t *testing.T) {
+	config.App.Issuer = "http://localhost:8080"
+	config.App.Signing.EncryptionKey = "test-encryption-key"
+
+	// Assertion jtis are remembered in memory instead of Postgres
+	seen := map[string]bool{}
+	previous := recordAssertionJTI
+	recordAssertionJTI = func(clientID, jti string, _ time.Time) (bool, error) {
+		if seen[clientID+":"+jti] {
+			return false, nil
+		}
+		seen[clientID+":"+jti] = true
+		return true, nil
+	}
+	t.Cleanup(func() { recordAssertionJTI = previous })
+
+	key, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	unregistered, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []interface{}{key.PublicJWK()}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	keyClient := &models.Client{ID: "key-client", TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT, JWKS: string(jwks)}
+
+	const secret = "client-secret"
+	encrypted, err := encryptKeyMaterial([]byte(secret))
+	if err != nil {
+		t.Fatalf("encryptKeyMaterial: %v", err)
+	}
+	secretClient := &models.Client{ID: "secret-client", TokenEndpointAuthMethod: AuthMethodClientSecretJWT, EncryptedSecret: encrypted}
+	expiredSecret := time.Now().Add(-time.Minute)
+	expiredClient := &models.Client{ID: "expired-client", TokenEndpointAuthMethod: AuthMethodClientSecretJWT,
+		EncryptedSecret: encrypted, SecretExpiresAt: &expiredSecret}
+	// Secrets of clients not registered with client_secret_jwt never verify assertions
+	postClient := &models.Client{ID: "post-client", TokenEndpointAuthMethod: AuthMethodClientSecretPost, EncryptedSecret: encrypted}
+
+	claims := func(clientID string, extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": clientID,
+			"sub": clientID,
+			"aud": "http://localhost:8080/token",
+			"exp": time.Now().Add(5 * time.Minute).Unix(),
+			"jti": uuid.NewString(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	hmac := func(c jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return signed
+	}
+
+	replayed := signWith(t, key, claims(keyClient.ID, nil), "")
+	if _, err := verifyClientJWT(keyClient, replayed, assertionRequest()); err != nil {
+		t.Fatalf("first use of the replayed assertion: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		client  *models.Client
+		token   string
+		wantErr bool
+	}{
+		{name: "registered key", client: keyClient, token: signWith(t, key, claims(keyClient.ID, nil), "")},
+		{name: "issuer as audience", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"aud": "http://localhost:8080"}), "")},
+		{name: "client secret", client: secretClient, token: hmac(claims(secretClient.ID, nil))},
+		{name: "unregistered key", client: keyClient, token: signWith(t, unregistered, claims(keyClient.ID, nil), ""), wantErr: true},
+		{name: "HMAC without client_secret_jwt", client: postClient, token: hmac(claims(postClient.ID, nil)), wantErr: true},
+		{name: "HMAC with a key client", client: keyClient, token: hmac(claims(keyClient.ID, nil)), wantErr: true},
+		{name: "expired client secret", client: expiredClient, token: hmac(claims(expiredClient.ID, nil)), wantErr: true},
+		{name: "other issuer", client: keyClient, token: signWith(t, key, claims("other-client", nil), ""), wantErr: true},
+		{name: "other audience", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"aud": "https://other.example.com"}), ""), wantErr: true},
+		{name: "expired", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), ""), wantErr: true},
+		{name: "no expiry", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"exp": nil}), ""), wantErr: true},
+		{name: "expires too late", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"exp": time.Now().Add(2 * maxAssertionLifetime).Unix()}), ""), wantErr: true},
+		{name: "no jti", client: keyClient, token: signWith(t, key, claims(keyClient.ID, jwt.MapClaims{"jti": nil}), ""), wantErr: true},
+		{name: "replayed", client: keyClient, token: replayed, wantErr: true},
+		{name: "unsigned", client: keyClient, token: unsignedJWT(t, claims(keyClient.ID, nil)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifyClientJWT(tt.client, tt.token, assertionRequest())
+			if tt.wantErr && err == nil {
+				t.Fatal("verifyClientJWT() error = nil, want an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("verifyClientJWT() error = %v", err)
+			}
+		})
+	}
+}
+
+func assertionRequest() *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/token", nil)
+}
+
+func unsignedJWT(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
@@ -0,0 +1,129 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+// clientCAs verifies the certificates of tls_client_auth clients, nil when
+// no client CA is configured
+var clientCAs *x509.CertPool
+
+// InitClientCertificateAuthorities loads the CAs issuing client certificates
+func InitClientCertificateAuthorities() error {
+	if config.App.TLS.ClientCAFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(config.App.TLS.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return errors.New("no certificate found in the client CA file")
+	}
+	clientCAs = pool
+	return nil
+}
+
+// clientCertificate returns the certificate presented on the TLS connection
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// CertificateThumbprint returns the base64url SHA-256 thumbprint of a
+// certificate, as used in x5t#S256
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authenticateClientCertificate authenticates a client identified by its
+// client_id with the certificate of the TLS connection (RFC 8705 section 2)
+func authenticateClientCertificate(r *http.Request) (*models.Client, string, error) {
+	cert := clientCertificate(r)
+	if cert == nil {
+		return nil, "", ErrInvalidClient
+	}
+
+	client, err := repositories.GetClientByID(r.FormValue("client_id"))
+	if err != nil {
+		return nil, "", ErrInvalidClient
+	}
+
+	switch client.TokenEndpointAuthMethod {
+	case AuthMethodTLSClientAuth:
+		if clientCAs == nil || client.TLSClientAuthSubjectDN == "" {
+			return nil, "", ErrInvalidClient
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range r.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         clientCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil || normalizeDN(cert.Subject.String()) != normalizeDN(client.TLSClientAuthSubjectDN) {
+			return nil, "", ErrInvalidClient
+		}
+
+	case AuthMethodSelfSignedTLSClientAuth:
+		thumbprint := CertificateThumbprint(cert)
+		registered := false
+		for _, t := range client.TLSClientCertificateThumbprints {
+			if t == thumbprint {
+				registered = true
+			}
+		}
+		if !registered {
+			return nil, "", ErrInvalidClient
+		}
+
+	default:
+		return nil, "", ErrInvalidClient
+	}
+
+	return client, client.TokenEndpointAuthMethod, nil
+}
+
+// normalizeDN makes subject DNs comparable regardless of case and of the
+// spaces around their separators
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		name, value, _ := strings.Cut(part, "=")
+		parts[i] = strings.ToUpper(strings.TrimSpace(name)) + "=" + strings.TrimSpace(value)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
+
+// ValidateClientCertificateSettings checks that clients using mutual-TLS
+// authentication registered the certificates they may present
+func ValidateClientCertificateSettings(method, subjectDN string, thumbprints []string) error {
+	switch method {
+	case AuthMethodTLSClientAuth:
+		if subjectDN == "" {
+			return fmt.Errorf("%s requires a certificate subject DN", method)
+		}
+	case AuthMethodSelfSignedTLSClientAuth:
+		if len(thumbprints) == 0 {
+			return fmt.Errorf("%s requires certificate thumbprints", method)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package oauth
+
+import "testing"
+
+func TestNormalizeDN(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{name: "identical", a: "CN=orders,O=Acme", b: "CN=orders,O=Acme", same: true},
+		{name: "spaces around separators", a: "CN=orders,O=Acme", b: " cn = orders , o = Acme ", same: true},
+		{name: "case", a: "CN=orders,O=Acme", b: "cn=ORDERS,o=acme", same: true},
+		{name: "other value", a: "CN=orders,O=Acme", b: "CN=billing,O=Acme", same: false},
+		{name: "other attribute order", a: "CN=orders,O=Acme", b: "O=Acme,CN=orders", same: false},
+		{name: "missing attribute", a: "CN=orders,O=Acme", b: "CN=orders", same: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeDN(tt.a) == normalizeDN(tt.b); got != tt.same {
+				t.Fatalf("normalizeDN(%q) == normalizeDN(%q) is %v, want %v (%q, %q)",
+					tt.a, tt.b, got, tt.same, normalizeDN(tt.a), normalizeDN(tt.b))
+			}
+		})
+	}
+}
@@ -3,9 +3,6 @@ package oauth
 import (
 	"encoding/json"
 	"net/http"
-	"zenauth/internal/repositories"
-
-	"github.com/google/uuid"
 )
 
 type ClientCredentialsFlow struct{}
@@ -15,32 +12,42 @@ func (f *ClientCredentialsFlow) Supports(grantType string) bool {
 }
 
 func (f *ClientCredentialsFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Request) {
-	clientID, clientSecret, ok := r.BasicAuth()
-	if !ok {
-		http.Error(w, "invalid_client", http.StatusUnauthorized)
+	client := tokenClient(r)
+	if !checkDPoPPolicy(w, r, client) {
 		return
 	}
+	clientID := client.ID
 
-	client, err := repositories.GetClientByID(clientID)
-	if err != nil || client.Secret != clientSecret {
-		http.Error(w, "invalid_client", http.StatusUnauthorized)
+	scope, err := ResolveScopes(client, r.FormValue("scope"))
+	if err == ErrInvalidScope {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
 		return
 	}
 
-	accessToken, err := GenerateAccessToken(clientID, "default")
+	accessToken, err := GenerateAccessToken(clientID, client, scope, tokenBinding(r))
 	if err != nil {
 		http.Error(w, "server_error", http.StatusInternalServerError)
 		return
 	}
 
-	refreshToken := uuid.NewString()
-	_ = repositories.StoreRefreshToken(refreshToken, clientID, nil)
+	refreshToken, err := issueRefreshToken(client, nil, scope, tokenBinding(r))
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
 
 	token := map[string]interface{}{
-		"access_token":  accessToken,
-		"token_type":    "bearer",
-		"expires_in":    3600,
-		"refresh_token": refreshToken,
+		"access_token": accessToken,
+		"token_type":   tokenBinding(r).tokenType(),
+		"expires_in":   int(AccessTokenLifetime(client).Seconds()),
+		"scope":        scope,
+	}
+	if refreshToken != "" {
+		token["refresh_token"] = refreshToken
 	}
 
 	w.Header().Set("Content-Type", "application/json")
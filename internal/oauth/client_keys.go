@@ -0,0 +1,197 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"zenauth/internal/models"
+)
+
+var ErrInvalidClientKeys = errors.New("invalid client keys")
+
+// ClientKey is a public key registered by a client to sign its assertions
+type ClientKey struct {
+	ID        string
+	PublicKey crypto.PublicKey
+}
+
+// jsonWebKey holds the JWK members ZenAuth understands
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseClientKeys reads the keys registered for a client, either a JSON Web
+// Key Set or one or more PEM encoded public keys
+func ParseClientKeys(data string) ([]ClientKey, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(data, "{") {
+		return parseJWKS([]byte(data))
+	}
+	return parsePEMKeys([]byte(data))
+}
+
+func parseJWKS(data []byte) ([]ClientKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClientKeys, err)
+	}
+
+	keys := make([]ClientKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		// Encryption keys are not used to verify signatures
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrInvalidClientKeys, jwk.Kid, err)
+		}
+		keys = append(keys, ClientKey{ID: jwk.Kid, PublicKey: pub})
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := dec(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := dec(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return pub, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := dec(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+func parsePEMKeys(data []byte) ([]ClientKey, error) {
+	var keys []ClientKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var pub crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				pub = cert.PublicKey
+			}
+		default:
+			err = fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidClientKeys, err)
+		}
+		keys = append(keys, ClientKey{PublicKey: pub})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no JWKS or PEM public key found", ErrInvalidClientKeys)
+	}
+	return keys, nil
+}
+
+// clientVerificationKeys returns the registered keys of the client that can
+// verify a token signed with the given algorithm, restricted to kid when set
+func clientVerificationKeys(client *models.Client, alg string, kid string) []crypto.PublicKey {
+	keys, err := ParseClientKeys(client.JWKS)
+	if err != nil {
+		return nil
+	}
+
+	var matching []crypto.PublicKey
+	for _, key := range keys {
+		if kid != "" && key.ID != "" && key.ID != kid {
+			continue
+		}
+		if keyMatchesAlgorithm(key.PublicKey, alg) {
+			matching = append(matching, key.PublicKey)
+		}
+	}
+	return matching
+}
+
+func keyMatchesAlgorithm(pub crypto.PublicKey, alg string) bool {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA
+	}
+	return false
+}
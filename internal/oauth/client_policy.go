@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+)
+
+var (
+	ErrUnauthorizedClient = errors.New("unauthorized_client")
+	ErrPKCERequired       = errors.New("pkce required")
+)
+
+// maxAccessTokenLifetime caps per-client access token lifetimes, and is how
+// long retired signing keys stay published
+const maxAccessTokenLifetime = 24 * time.Hour
+
+// confidentialGrantTypes need an authenticated client, which public clients cannot be
+var confidentialGrantTypes = []string{"client_credentials", TokenExchangeGrantType, JWTBearerGrantType}
+
+// GrantAllowed reports whether the client may use the grant type
+func GrantAllowed(client *models.Client, grantType string) bool {
+	if client.IsPublic() && hasGrantType(confidentialGrantTypes, grantType) {
+		return false
+	}
+	return len(client.GrantTypes) == 0 || hasGrantType(client.GrantTypes, grantType)
+}
+
+// CheckGrantTypes rejects grant types ZenAuth does not know
+func CheckGrantTypes(grantTypes []string) error {
+	for _, grantType := range grantTypes {
+		if !hasGrantType(GrantTypes, grantType) {
+			return fmt.Errorf("unsupported grant type: %s", grantType)
+		}
+	}
+	return nil
+}
+
+// CheckClientPolicy validates the type, grant types and token lifetime of a
+// client. An empty type defaults to confidential, and public clients default
+// to the none auth method
+func CheckClientPolicy(client *models.Client) error {
+	switch client.Type {
+	case "":
+		client.Type = models.ClientConfidential
+	case models.ClientConfidential, models.ClientPublic:
+	default:
+		return fmt.Errorf("unsupported client type: %s", client.Type)
+	}
+
+	if client.IsPublic() {
+		if client.TokenEndpointAuthMethod == "" {
+			client.TokenEndpointAuthMethod = AuthMethodNone
+		}
+		if client.TokenEndpointAuthMethod != AuthMethodNone {
+			return fmt.Errorf("public clients must use the %s auth method", AuthMethodNone)
+		}
+		for _, grantType := range confidentialGrantTypes {
+			if hasGrantType(client.GrantTypes, grantType) {
+				return fmt.Errorf("public clients cannot use the %s grant", grantType)
+			}
+		}
+	} else if client.TokenEndpointAuthMethod == AuthMethodNone {
+		return fmt.Errorf("confidential clients must authenticate")
+	}
+
+	if err := CheckGrantTypes(client.GrantTypes); err != nil {
+		return err
+	}
+
+	if client.AccessTokenLifetime < 0 || time.Duration(client.AccessTokenLifetime)*time.Second > maxAccessTokenLifetime {
+		return fmt.Errorf("access token lifetime must be between 0 and %d seconds", int(maxAccessTokenLifetime.Seconds()))
+	}
+	return nil
+}
+
+// CheckAuthorizationRequest applies the client policy to an authorization
+// request: the client must be allowed to use codes, and public clients must
+// protect them with an S256 PKCE challenge
+func CheckAuthorizationRequest(client *models.Client, codeChallenge, codeChallengeMethod string) error {
+	if !GrantAllowed(client, "authorization_code") {
+		return ErrUnauthorizedClient
+	}
+	if client.IsPublic() && (codeChallenge == "" || codeChallengeMethod != "S256") {
+		return ErrPKCERequired
+	}
+	return nil
+}
+
+// AccessTokenLifetime returns how long the access tokens of the client are valid
+func AccessTokenLifetime(client *models.Client) time.Duration {
+	lifetime := config.App.AccessToken.Lifetime
+	if client.AccessTokenLifetime > 0 {
+		lifetime = time.Duration(client.AccessTokenLifetime) * time.Second
+	}
+	if lifetime <= 0 || lifetime > maxAccessTokenLifetime {
+		lifetime = maxAccessTokenLifetime
+	}
+	return lifetime
+}
+
+// checkDPoPPolicy requires a DPoP proof when the tokens of the client must be
+// bound to a key. It writes the error response. The grant types of the client
+// are enforced by TokenHandler before the flows run
+func checkDPoPPolicy(w http.ResponseWriter, r *http.Request, client *models.Client) bool {
+	if DPoPRequired(client) && tokenBinding(r).JKT == "" {
+		http.Error(w, "invalid_dpop_proof", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
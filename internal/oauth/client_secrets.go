@@ -0,0 +1,197 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// clientSecretBytes is the entropy of generated secrets, which stays below
+// the 72 bytes bcrypt can hash once encoded
+const clientSecretBytes = 32
+
+// ClientNeedsSecret reports whether a client registered with the given
+// token endpoint auth method authenticates with a secret
+func ClientNeedsSecret(method string) bool {
+	switch method {
+	case AuthMethodPrivateKeyJWT, AuthMethodTLSClientAuth, AuthMethodSelfSignedTLSClientAuth, AuthMethodNone:
+		return false
+	}
+	return true
+}
+
+// ClientNeedsNewSecret reports whether a client must be given a secret: it
+// uses a secret based method but has none, or none it can sign assertions with
+func ClientNeedsNewSecret(client *models.Client) bool {
+	if !ClientNeedsSecret(client.TokenEndpointAuthMethod) {
+		return false
+	}
+	if client.TokenEndpointAuthMethod == AuthMethodClientSecretJWT {
+		return client.SecretHash == "" || client.EncryptedSecret == nil
+	}
+	return client.SecretHash == ""
+}
+
+// SetClientSecret generates a new secret for the client and replaces both
+// of its secrets with it. The plaintext is returned so it can be shown once
+func SetClientSecret(client *models.Client) (string, error) {
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := encryptClientSecret(client, secret)
+	if err != nil {
+		return "", err
+	}
+
+	client.SecretHash = hash
+	client.EncryptedSecret = encrypted
+	client.SecretExpiresAt = clientSecretExpiry()
+	client.PreviousSecretHash = ""
+	client.PreviousEncryptedSecret = nil
+	client.PreviousSecretExpiresAt = nil
+	return secret, nil
+}
+
+// RotateClientSecret generates a new secret for the client. The current one
+// keeps working for the grace period, so that the client can be redeployed
+// with the new secret without downtime
+func RotateClientSecret(client *models.Client, grace time.Duration) (string, error) {
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := encryptClientSecret(client, secret)
+	if err != nil {
+		return "", err
+	}
+
+	client.PreviousSecretHash = ""
+	client.PreviousEncryptedSecret = nil
+	client.PreviousSecretExpiresAt = nil
+	if client.SecretHash != "" && grace > 0 && !secretExpired(client.SecretExpiresAt) {
+		expiresAt := time.Now().Add(grace)
+		if client.SecretExpiresAt != nil && client.SecretExpiresAt.Before(expiresAt) {
+			expiresAt = *client.SecretExpiresAt
+		}
+		client.PreviousSecretHash = client.SecretHash
+		client.PreviousEncryptedSecret = client.EncryptedSecret
+		client.PreviousSecretExpiresAt = &expiresAt
+	}
+
+	client.SecretHash = hash
+	client.EncryptedSecret = encrypted
+	client.SecretExpiresAt = clientSecretExpiry()
+	return secret, nil
+}
+
+// RevokePreviousClientSecret ends a rotation early
+func RevokePreviousClientSecret(client *models.Client) {
+	client.PreviousSecretHash = ""
+	client.PreviousEncryptedSecret = nil
+	client.PreviousSecretExpiresAt = nil
+}
+
+// DiscardEncryptedClientSecrets drops the encrypted secrets of a client
+// that is not, or no longer, registered with client_secret_jwt
+func DiscardEncryptedClientSecrets(client *models.Client) {
+	if client.TokenEndpointAuthMethod != AuthMethodClientSecretJWT {
+		client.EncryptedSecret = nil
+		client.PreviousEncryptedSecret = nil
+	}
+}
+
+// VerifyClientSecret checks a secret against the current and the previous
+// secret of the client. bcrypt compares the hashes in constant time
+func VerifyClientSecret(client *models.Client, secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	candidates := []struct {
+		hash      string
+		expiresAt *time.Time
+	}{
+		{client.SecretHash, client.SecretExpiresAt},
+		{client.PreviousSecretHash, client.PreviousSecretExpiresAt},
+	}
+	for _, c := range candidates {
+		if c.hash == "" || secretExpired(c.expiresAt) {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(c.hash), []byte(secret)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// clientSecretKeys returns the unexpired secrets verifying the client's
+// client_secret_jwt assertions
+func clientSecretKeys(client *models.Client) [][]byte {
+	if client.TokenEndpointAuthMethod != AuthMethodClientSecretJWT {
+		return nil
+	}
+
+	candidates := []struct {
+		encrypted []byte
+		expiresAt *time.Time
+	}{
+		{client.EncryptedSecret, client.SecretExpiresAt},
+		{client.PreviousEncryptedSecret, client.PreviousSecretExpiresAt},
+	}
+	var keys [][]byte
+	for _, c := range candidates {
+		if c.encrypted == nil || secretExpired(c.expiresAt) {
+			continue
+		}
+		secret, err := decryptKeyMaterial(c.encrypted)
+		if err != nil {
+			log.Printf("Failed to decrypt a secret of client %s: %v", client.ID, err)
+			continue
+		}
+		keys = append(keys, secret)
+	}
+	return keys
+}
+
+// encryptClientSecret keeps a copy of the secret the server can read, only
+// for clients signing assertions with it
+func encryptClientSecret(client *models.Client, secret string) ([]byte, error) {
+	if client.TokenEndpointAuthMethod != AuthMethodClientSecretJWT {
+		return nil, nil
+	}
+	return encryptKeyMaterial([]byte(secret))
+}
+
+func generateClientSecret() (string, string, error) {
+	b := make([]byte, clientSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, string(hash), nil
+}
+
+func clientSecretExpiry() *time.Time {
+	if config.App.ClientSecret.Lifetime <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(config.App.ClientSecret.Lifetime)
+	return &expiresAt
+}
+
+func secretExpired(expiresAt *time.Time) bool {
+	return expiresAt != nil && time.Now().After(*expiresAt)
+}
@@ -0,0 +1,71 @@
+package oauth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+var ErrConsentNotFound = errors.New("consent not found")
+
+// ConsentRequired reports whether the user must approve the scope before a
+// code is issued: first-party clients are exempt, and returning users are
+// only asked again when the client requests scopes they have not granted yet
+func ConsentRequired(client *models.Client, userID, scope string) (bool, error) {
+	if client.ConsentExempt {
+		return false, nil
+	}
+
+	consent, err := repositories.GetConsent(userID, client.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	for _, s := range uniqueScopes(scope) {
+		if !containsScope(consent.Scopes, s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevokeConsent removes a user's grant to a client and revokes the refresh
+// tokens issued under it. revokedBy identifies who asked, for the audit log
+func RevokeConsent(userID, clientID, revokedBy string) error {
+	revoked, err := repositories.RevokeConsent(userID, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConsentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Consent of user %s to client %s revoked by %s, %d refresh tokens revoked",
+		userID, clientID, revokedBy, revoked)
+
+	err = repositories.RecordAuditEvent(&models.AuditEvent{
+		Type:     models.AuditConsentRevoked,
+		ClientID: clientID,
+		UserID:   &userID,
+		Details:  fmt.Sprintf("revoked by %s, %d refresh tokens revoked", revokedBy, revoked),
+	})
+	if err != nil {
+		log.Printf("Failed to record audit event: %v", err)
+	}
+	return nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	// Consonants only, so that user codes cannot spell words (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+
+	// Added to the polling interval of a client that polls too fast
+	slowDownIncrement = 5
+)
+
+// StartDeviceAuthorization creates a device code and user code for the client
+func StartDeviceAuthorization(client *models.Client, requestedScope string) (*models.DeviceCode, error) {
+	scope, err := ResolveScopes(client, requestedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	device := &models.DeviceCode{
+		DeviceCode: generateRandomToken(),
+		UserCode:   userCode,
+		ClientID:   client.ID,
+		Scope:      scope,
+		Status:     models.DeviceCodePending,
+		Interval:   int(config.App.DeviceCode.PollInterval.Seconds()),
+		ExpiresAt:  time.Now().Add(config.App.DeviceCode.Lifetime),
+	}
+	if err := repositories.StoreDeviceCode(device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func generateUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeUserCode accepts user codes typed in lower case or with separators
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r < 'A' || r > 'Z' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// FormatUserCode splits a user code in two halves for readability
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+type DeviceCodeFlow struct{}
+
+func (f *DeviceCodeFlow) Supports(grantType string) bool {
+	return grantType == DeviceCodeGrantType
+}
+
+func (f *DeviceCodeFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Request) {
+	client := tokenClient(r)
+	if !checkDPoPPolicy(w, r, client) {
+		return
+	}
+
+	device, err := repositories.GetDeviceCode(r.FormValue("device_code"))
+	if err != nil || device.ClientID != client.ID {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if now.After(device.ExpiresAt) {
+		http.Error(w, "expired_token", http.StatusBadRequest)
+		return
+	}
+
+	switch device.Status {
+	case models.DeviceCodePending:
+		// Clients polling faster than the interval are told to slow down
+		interval := device.Interval
+		tooFast := device.LastPolledAt != nil && now.Sub(*device.LastPolledAt) < time.Duration(interval)*time.Second
+		if tooFast {
+			interval += slowDownIncrement
+		}
+		if err := repositories.RecordDevicePoll(device.DeviceCode, interval); err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+
+		if tooFast {
+			http.Error(w, "slow_down", http.StatusBadRequest)
+		} else {
+			http.Error(w, "authorization_pending", http.StatusBadRequest)
+		}
+		return
+
+	case models.DeviceCodeDenied:
+		_ = repositories.DeleteDeviceCode(device.DeviceCode)
+		http.Error(w, "access_denied", http.StatusBadRequest)
+		return
+	}
+
+	// Approved: the device code can only be exchanged once
+	if err := repositories.DeleteDeviceCode(device.DeviceCode); err != nil || device.UserID == nil {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	var authTime time.Time
+	if device.AuthTime != nil {
+		authTime = *device.AuthTime
+	}
+
+	token, err := userTokenResponse(client, *device.UserID, device.Scope, "", authTime, tokenBinding(r))
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
@@ -0,0 +1,269 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// DPoP proofs (RFC 9449)
+const (
+	DPoPHeader    = "DPoP"
+	DPoPTokenType = "DPoP"
+	dpopProofType = "dpop+jwt"
+
+	// How old a proof may be, and how far ahead of our clock its iat may be
+	dpopProofMaxAge    = 5 * time.Minute
+	dpopProofClockSkew = time.Minute
+)
+
+var (
+	ErrInvalidDPoPProof = errors.New("invalid_dpop_proof")
+	ErrInvalidToken     = errors.New("invalid_token")
+)
+
+// DPoPSigningAlgorithms lists the algorithms accepted for DPoP proofs, which
+// are signed with the public key they carry
+var DPoPSigningAlgorithms = asymmetricSigningAlgorithms
+
+// recordDPoPProofJTI remembers the jti of a proof until it expires, and
+// reports whether it was used for the first time
+var recordDPoPProofJTI = repositories.RecordDPoPProofJTI
+
+type dpopContextKey struct{}
+
+// TokenBinding is the key tokens are bound to, published in their cnf claim
+type TokenBinding struct {
+	JKT string // Thumbprint of the DPoP proof key
+	X5T string // Thumbprint of the TLS client certificate (RFC 8705)
+}
+
+// cnf returns the confirmation claim of the binding, nil for bearer tokens
+func (b TokenBinding) cnf() map[string]interface{} {
+	if b.JKT == "" && b.X5T == "" {
+		return nil
+	}
+	cnf := map[string]interface{}{}
+	if b.JKT != "" {
+		cnf["jkt"] = b.JKT
+	}
+	if b.X5T != "" {
+		cnf["x5t#S256"] = b.X5T
+	}
+	return cnf
+}
+
+// proves reports whether the binding holds every key the token with the
+// given claims is bound to
+func (b TokenBinding) proves(claims jwt.MapClaims) bool {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	if jkt, _ := cnf["jkt"].(string); jkt != "" && jkt != b.JKT {
+		return false
+	}
+	if x5t, _ := cnf["x5t#S256"].(string); x5t != "" && x5t != b.X5T {
+		return false
+	}
+	return true
+}
+
+// tokenType returns the token_type of the access tokens issued with the binding
+func (b TokenBinding) tokenType() string {
+	if b.JKT != "" {
+		return DPoPTokenType
+	}
+	return "bearer"
+}
+
+// WithDPoPProof verifies the DPoP proof sent to the token endpoint, if any,
+// and records the key it binds the issued tokens to in the request context
+func WithDPoPProof(r *http.Request) (*http.Request, error) {
+	if r.Header.Get(DPoPHeader) == "" {
+		return r, nil
+	}
+	jkt, err := VerifyDPoPProof(r, "")
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), dpopContextKey{}, jkt)), nil
+}
+
+// tokenBinding returns the keys the tokens issued for the request are bound
+// to: the DPoP proof key and the TLS client certificate, when present
+func tokenBinding(r *http.Request) TokenBinding {
+	jkt, _ := r.Context().Value(dpopContextKey{}).(string)
+	binding := TokenBinding{JKT: jkt}
+	if cert := clientCertificate(r); cert != nil {
+		binding.X5T = CertificateThumbprint(cert)
+	}
+	return binding
+}
+
+// DPoPRequired reports whether the client may only get DPoP-bound tokens
+func DPoPRequired(client *models.Client) bool {
+	return client.DPoPBoundAccessTokens
+}
+
+// VerifyDPoPProof verifies the DPoP proof of a request (RFC 9449 section 4.3)
+// and returns the JWK thumbprint of its key. accessToken is the token the
+// proof is presented with at a resource, empty at the token endpoint
+func VerifyDPoPProof(r *http.Request, accessToken string) (string, error) {
+	proofs := r.Header.Values(DPoPHeader)
+	if len(proofs) != 1 {
+		return "", ErrInvalidDPoPProof
+	}
+
+	// The proof is signed by the public key it carries in its header
+	// Its iat is checked below, allowing for clock skew, so the parser must not
+	// reject proofs issued in the future
+	var jwk jsonWebKey
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(proofs[0], func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != dpopProofType {
+			return nil, ErrInvalidDPoPProof
+		}
+		if !algorithmSupported(DPoPSigningAlgorithms, token.Method.Alg()) {
+			return nil, ErrInvalidDPoPProof
+		}
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		var members map[string]interface{}
+		if json.Unmarshal(raw, &members) != nil || members["d"] != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		if err := json.Unmarshal(raw, &jwk); err != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		key, err := jwk.publicKey()
+		if err != nil || !keyMatchesAlgorithm(key, token.Method.Alg()) {
+			return nil, ErrInvalidDPoPProof
+		}
+		return key, nil
+	})
+	if err != nil || !token.Valid {
+		return "", ErrInvalidDPoPProof
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	htm, _ := claims["htm"].(string)
+	htu, _ := claims["htu"].(string)
+	jti, _ := claims["jti"].(string)
+	iat, ok := claims["iat"].(float64)
+	if !ok || jti == "" || htm != r.Method || !dpopTargetMatches(htu, r) {
+		return "", ErrInvalidDPoPProof
+	}
+
+	issuedAt := time.Unix(int64(iat), 0)
+	now := time.Now()
+	if issuedAt.Before(now.Add(-dpopProofMaxAge)) || issuedAt.After(now.Add(dpopProofClockSkew)) {
+		return "", ErrInvalidDPoPProof
+	}
+
+	// At a resource, the proof must be made for the presented token
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", ErrInvalidDPoPProof
+		}
+	}
+
+	jkt, err := jwk.thumbprint()
+	if err != nil {
+		return "", ErrInvalidDPoPProof
+	}
+
+	// A proof is single-use: remember its jti for as long as it is acceptable,
+	// in Postgres so that every instance sees it
+	fresh, err := recordDPoPProofJTI(jkt, jti, issuedAt.Add(dpopProofMaxAge))
+	if err != nil || !fresh {
+		return "", ErrInvalidDPoPProof
+	}
+
+	return jkt, nil
+}
+
+// dpopTargetMatches compares the htu claim with the URL of the request, as
+// published under the issuer, ignoring query and fragment
+func dpopTargetMatches(htu string, r *http.Request) bool {
+	if i := strings.IndexAny(htu, "?#"); i >= 0 {
+		htu = htu[:i]
+	}
+	return htu == strings.TrimSuffix(config.App.Issuer, "/")+r.URL.Path
+}
+
+// thumbprint computes the JWK SHA-256 thumbprint (RFC 7638) from the
+// required members of the key, which encoding/json sorts by name
+func (jwk jsonWebKey) thumbprint() (string, error) {
+	var members map[string]string
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case "EC":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X, "y": jwk.Y}
+	case "OKP":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	default:
+		return "", ErrInvalidDPoPProof
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ValidateResourceRequest validates the access token of a request to a
+// protected resource, sent with the Bearer or the DPoP scheme. Tokens bound
+// to a DPoP key must come with the DPoP scheme and a proof made with the key,
+// tokens bound to a certificate over a TLS connection authenticated with it
+func ValidateResourceRequest(r *http.Request) (*jwt.Token, error) {
+	scheme, tokenString, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if tokenString == "" || (scheme != "Bearer" && scheme != DPoPTokenType) {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := ValidateAccessToken(tokenString)
+	if err != nil {
+		return token, err
+	}
+	if !token.Valid {
+		return token, ErrInvalidToken
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	boundJKT, _ := cnf["jkt"].(string)
+
+	if boundX5T, _ := cnf["x5t#S256"].(string); boundX5T != "" {
+		cert := clientCertificate(r)
+		if cert == nil || CertificateThumbprint(cert) != boundX5T {
+			return token, ErrInvalidToken
+		}
+	}
+
+	if scheme == "Bearer" {
+		if boundJKT != "" {
+			return token, ErrInvalidDPoPProof
+		}
+		return token, nil
+	}
+
+	jkt, err := VerifyDPoPProof(r, tokenString)
+	if err != nil || boundJKT == "" || jkt != boundJKT {
+		return token, ErrInvalidDPoPProof
+	}
+	return token, nil
+}
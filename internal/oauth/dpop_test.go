@@ -0,0 +1,147 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zenauth/config"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+func TestVerifyDPoPProof(t *testing.T) {
+	config.App.Issuer = "http://localhost:8080"
+
+	// Proof jtis are remembered in memory instead of Postgres
+	seen := map[string]bool{}
+	previous := recordDPoPProofJTI
+	recordDPoPProofJTI = func(jkt, jti string, _ time.Time) (bool, error) {
+		if seen[jkt+":"+jti] {
+			return false, nil
+		}
+		seen[jkt+":"+jti] = true
+		return true, nil
+	}
+	t.Cleanup(func() { recordDPoPProofJTI = previous })
+
+	key, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	jkt, err := key.thumbprint()
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+
+	const accessToken = "access-token"
+	ath := sha256.Sum256([]byte(accessToken))
+
+	// proof signs a valid proof for POST /token, after mutate changed it
+	proof := func(mutate func(token *jwt.Token, claims jwt.MapClaims)) string {
+		claims := jwt.MapClaims{
+			"htm": "POST",
+			"htu": "http://localhost:8080/token",
+			"iat": time.Now().Unix(),
+			"jti": uuid.NewString(),
+		}
+		token := jwt.NewWithClaims(key.method(), claims)
+		token.Header["typ"] = dpopProofType
+		token.Header["jwk"] = key.publicMembers()
+		if mutate != nil {
+			mutate(token, claims)
+		}
+
+		var signingKey interface{} = key.PrivateKey
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			signingKey = []byte("secret")
+		}
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return signed
+	}
+
+	replayed := proof(nil)
+	if _, err := VerifyDPoPProof(dpopRequest(replayed), ""); err != nil {
+		t.Fatalf("first use of the replayed proof: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		proofs      []string
+		accessToken string
+		wantErr     bool
+	}{
+		{name: "valid proof", proofs: []string{proof(nil)}},
+		{name: "query and fragment are ignored", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["htu"] = "http://localhost:8080/token?x=1#y"
+		})}},
+		{name: "proof for the access token", accessToken: accessToken, proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+		})}},
+		{name: "no proof", wantErr: true},
+		{name: "two proofs", proofs: []string{proof(nil), proof(nil)}, wantErr: true},
+		{name: "replayed", proofs: []string{replayed}, wantErr: true},
+		{name: "wrong typ", proofs: []string{proof(func(tok *jwt.Token, _ jwt.MapClaims) { tok.Header["typ"] = "JWT" })}, wantErr: true},
+		{name: "symmetric algorithm", proofs: []string{proof(func(tok *jwt.Token, _ jwt.MapClaims) {
+			tok.Method = jwt.SigningMethodHS256
+			tok.Header["alg"] = tok.Method.Alg()
+		})}, wantErr: true},
+		{name: "private key in the header", proofs: []string{proof(func(tok *jwt.Token, _ jwt.MapClaims) {
+			jwk := key.publicMembers()
+			jwk["d"] = "AAAA"
+			tok.Header["jwk"] = jwk
+		})}, wantErr: true},
+		{name: "other method", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) { c["htm"] = "GET" })}, wantErr: true},
+		{name: "other URL", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["htu"] = "http://localhost:8080/userinfo"
+		})}, wantErr: true},
+		{name: "no jti", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) { delete(c, "jti") })}, wantErr: true},
+		{name: "too old", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(-dpopProofMaxAge - time.Minute).Unix()
+		})}, wantErr: true},
+		{name: "issued within the clock skew", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(dpopProofClockSkew / 2).Unix()
+		})}},
+		{name: "issued in the future", proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(dpopProofClockSkew + time.Minute).Unix()
+		})}, wantErr: true},
+		{name: "no ath at a resource", accessToken: accessToken, proofs: []string{proof(nil)}, wantErr: true},
+		{name: "ath of another token", accessToken: accessToken, proofs: []string{proof(func(_ *jwt.Token, c jwt.MapClaims) {
+			other := sha256.Sum256([]byte("other-token"))
+			c["ath"] = base64.RawURLEncoding.EncodeToString(other[:])
+		})}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyDPoPProof(dpopRequest(tt.proofs...), tt.accessToken)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("VerifyDPoPProof() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyDPoPProof() error = %v", err)
+			}
+			if got != jkt {
+				t.Fatalf("VerifyDPoPProof() = %q, want the key thumbprint %q", got, jkt)
+			}
+		})
+	}
+}
+
+// dpopRequest builds a token request carrying the given DPoP headers
+func dpopRequest(proofs ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/token", nil)
+	for _, p := range proofs {
+		r.Header.Add(DPoPHeader, p)
+	}
+	return r
+}
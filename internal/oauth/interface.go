@@ -6,3 +6,27 @@ type OAuthFlow interface {
 	Supports(grantType string) bool
 	HandleTokenRequest(w http.ResponseWriter, r *http.Request)
 }
+
+// GrantTypes lists the grant types ZenAuth knows about, in the order they are advertised
+var GrantTypes = []string{
+	"authorization_code",
+	"client_credentials",
+	"refresh_token",
+	DeviceCodeGrantType,
+	TokenExchangeGrantType,
+	JWTBearerGrantType,
+}
+
+// SupportedGrantTypes returns the known grant types handled by at least one flow
+func SupportedGrantTypes(flows []OAuthFlow) []string {
+	supported := []string{}
+	for _, grantType := range GrantTypes {
+		for _, flow := range flows {
+			if flow.Supports(grantType) {
+				supported = append(supported, grantType)
+				break
+			}
+		}
+	}
+	return supported
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"time"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// IntrospectToken describes the state of an access or refresh token (RFC 7662).
+// Invalid, expired and revoked tokens are reported as inactive
+func IntrospectToken(token, tokenTypeHint string) map[string]interface{} {
+	if tokenTypeHint == "refresh_token" {
+		if resp := introspectRefreshToken(token); resp != nil {
+			return resp
+		}
+		if resp := introspectAccessToken(token); resp != nil {
+			return resp
+		}
+	} else {
+		if resp := introspectAccessToken(token); resp != nil {
+			return resp
+		}
+		if resp := introspectRefreshToken(token); resp != nil {
+			return resp
+		}
+	}
+
+	return map[string]interface{}{"active": false}
+}
+
+// introspectAccessToken also describes tokens exchanged for another audience,
+// which resource servers introspect, with that audience in "aud"
+func introspectAccessToken(token string) map[string]interface{} {
+	parsed, err := parseAccessToken(token)
+	if err != nil || !parsed.Valid {
+		return nil
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+
+	resp := map[string]interface{}{
+		"active":     true,
+		"token_type": "Bearer",
+	}
+	if _, bound := claims["cnf"]; bound {
+		resp["token_type"] = DPoPTokenType
+	}
+	for _, name := range []string{"scope", "client_id", "sub", "exp", "iat", "aud", "jti", "roles", "cnf"} {
+		if v, ok := claims[name]; ok {
+			resp[name] = v
+		}
+	}
+	return resp
+}
+
+func introspectRefreshToken(token string) map[string]interface{} {
+	stored, err := repositories.GetRefreshToken(token)
+	if err != nil {
+		return nil
+	}
+
+	// Expired and idle tokens are kept until the sweeper deletes them
+	client, err := repositories.GetClientByID(stored.ClientID)
+	if err != nil || refreshTokenExpired(client, stored, time.Now()) {
+		return nil
+	}
+
+	subject := stored.ClientID
+	if stored.UserID != nil {
+		subject = *stored.UserID
+	}
+
+	resp := map[string]interface{}{
+		"active":     true,
+		"token_type": "refresh_token",
+		"client_id":  stored.ClientID,
+		"sub":        subject,
+		"iat":        stored.IssuedAt.Unix(),
+	}
+	if scope, err := refreshTokenScope(client, stored); err == nil {
+		resp["scope"] = scope
+	}
+	if stored.ExpiresAt != nil {
+		resp["exp"] = stored.ExpiresAt.Unix()
+	}
+
+	if roleNames := userRoleNames(subject); len(roleNames) > 0 {
+		resp["roles"] = roleNames
+	}
+
+	return resp
+}
@@ -2,55 +2,183 @@ package oauth
 
 import (
 	"context"
+	"errors"
 	"time"
 	"zenauth/config"
 	rProviders "zenauth/internal/adapters/role"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
-// GenerateAccessToken crée un nouveau JWT token d'accès
-func GenerateAccessToken(subject string, scope string) (string, error) {
+const (
+	// accessTokenAudience est l'audience des tokens d'accès destinés à ZenAuth
+	accessTokenAudience = "zenauth"
+
+	// accessTokenJWTType est le header "typ" des tokens d'accès (RFC 9068),
+	// qui les distingue des ID tokens et logout tokens signés avec la même clé
+	accessTokenJWTType = "at+jwt"
+)
+
+var ErrNotAccessToken = errors.New("not an access token")
+
+// GenerateAccessToken crée un nouveau JWT token d'accès, valide pendant la
+// durée configurée pour le client (voir AccessTokenLifetime) et lié à la clé
+// de binding si elle est définie
+func GenerateAccessToken(subject string, client *models.Client, scope string, binding TokenBinding) (string, error) {
+	return signAccessToken(accessTokenClaims(subject, client, scope, binding))
+}
+
+// signAccessToken signe les claims d'un token d'accès avec le type at+jwt
+func signAccessToken(claims jwt.MapClaims) (string, error) {
+	return signTypedToken(claims, accessTokenJWTType)
+}
+
+// accessTokenClaims construit les claims d'un token d'accès
+func accessTokenClaims(subject string, client *models.Client, scope string, binding TokenBinding) jwt.MapClaims {
 	claims := jwt.MapClaims{
-		"sub":   subject,
-		"aud":   "zenauth",
-		"scope": scope,
-		"exp":   time.Now().Add(time.Hour).Unix(),
-		"iat":   time.Now().Unix(),
+		"sub":       subject,
+		"aud":       accessTokenAudience,
+		"client_id": client.ID,
+		"scope":     scope,
+		"jti":       uuid.NewString(),
+		"exp":       time.Now().Add(AccessTokenLifetime(client)).Unix(),
+		"iat":       time.Now().Unix(),
+	}
+
+	// Confirmation de la clé à laquelle le token est lié (RFC 9449)
+	if cnf := binding.cnf(); cnf != nil {
+		claims["cnf"] = cnf
 	}
 
 	// Inclure les rôles dans le JWT si configuré
-	if config.App.RoleManager.IncludeRolesInJWT && rProviders.CurrentManager != nil {
-		ctx := context.Background()
-
-		// Récupérer les rôles de l'utilisateur
-		roles, err := rProviders.CurrentManager.GetUserRoles(ctx, subject)
-		if err == nil && len(roles) > 0 {
-			// // Extraire uniquement les IDs des rôles pour le JWT
-			// roleIDs := make([]string, len(roles))
-			// for i, r := range roles {
-			// 	roleIDs[i] = r.ID
-			// }
-
-			// claims["roles"] = roleIDs
-
-			roleNames := make([]string, len(roles))
-			for i, r := range roles {
-				roleNames[i] = r.Name
-			}
+	if config.App.RoleManager.IncludeRolesInJWT {
+		if roleNames := userRoleNames(subject); len(roleNames) > 0 {
 			claims["roles"] = roleNames
 		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.App.JWTSecret))
+	return claims
+}
+
+// userRoleNames récupère les noms des rôles de l'utilisateur
+func userRoleNames(subject string) []string {
+	if rProviders.CurrentManager == nil {
+		return nil
+	}
+
+	roles, err := rProviders.CurrentManager.GetUserRoles(context.Background(), subject)
+	if err != nil {
+		return nil
+	}
+
+	// // Extraire uniquement les IDs des rôles pour le JWT
+	// roleIDs := make([]string, len(roles))
+	// for i, r := range roles {
+	// 	roleIDs[i] = r.ID
+	// }
+
+	roleNames := make([]string, len(roles))
+	for i, r := range roles {
+		roleNames[i] = r.Name
+	}
+	return roleNames
+}
+
+// signToken signe les claims avec la clé de signature active, ou avec le
+// secret partagé en HS256 si aucune clé asymétrique n'est configurée
+func signToken(claims jwt.MapClaims) (string, error) {
+	return signTypedToken(claims, "")
+}
+
+// signTypedToken signe les claims en précisant le header "typ" (ex: logout+jwt)
+func signTypedToken(claims jwt.MapClaims, typ string) (string, error) {
+	var token *jwt.Token
+	var signingKey interface{}
+
+	key := signingKeys.current()
+	if key == nil {
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		signingKey = []byte(config.App.JWTSecret)
+	} else {
+		token = jwt.NewWithClaims(key.method(), claims)
+		token.Header["kid"] = key.ID
+		signingKey = key.PrivateKey
+	}
+
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(signingKey)
 }
 
+// ValidateAccessToken vérifie un token d'accès destiné à ZenAuth : signature,
+// validité, type at+jwt et audience. Les tokens échangés pour une autre
+// audience (RFC 8693) ne sont pas acceptés par les endpoints de ZenAuth
 func ValidateAccessToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	token, err := parseAccessToken(tokenString)
+	if err != nil {
+		return token, err
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if aud, _ := claims["aud"].(string); aud != accessTokenAudience {
+		token.Valid = false
+		return token, ErrNotAccessToken
+	}
+	return token, nil
+}
+
+// parseAccessToken vérifie un token d'accès émis par ZenAuth, quelle que soit
+// son audience : signature, validité, type at+jwt et révocation
+func parseAccessToken(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
+	if err != nil {
+		return token, err
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	// Les ID tokens (sans typ) et les logout tokens (logout+jwt) sont refusés
+	if typ, _ := token.Header["typ"].(string); typ != accessTokenJWTType {
+		token.Valid = false
+		return token, ErrNotAccessToken
+	}
+
+	// Rejeter les tokens révoqués via /revoke
+	if config.App.Revocation.DenylistAccessTokens {
+		jti, _ := claims["jti"].(string)
+		if jti != "" {
+			revoked, err := repositories.IsAccessTokenRevoked(jti)
+			if err != nil {
+				token.Valid = false
+				return token, err
+			}
+			if revoked {
+				token.Valid = false
+				return token, ErrTokenRevoked
+			}
+		}
+	}
+
+	return token, nil
+}
+
+// verificationKey sélectionne la clé publique correspondant au kid du token
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		// Le secret partagé n'est accepté que si aucune clé asymétrique n'est active
+		if signingKeys.current() != nil {
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return []byte(config.App.JWTSecret), nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key := signingKeys.get(kid)
+	if key == nil || key.Algorithm != token.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.PrivateKey.Public(), nil
 }
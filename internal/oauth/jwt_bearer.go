@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const JWTBearerGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// JWTBearerFlow issues an access token for a JWT assertion signed by a
+// client (RFC 7523 section 2.1). The assertion subject is either the client
+// itself or a user who already granted the requested scopes to the client.
+// No refresh token is issued: the client signs a new assertion instead
+type JWTBearerFlow struct{}
+
+func (f *JWTBearerFlow) Supports(grantType string) bool {
+	return grantType == JWTBearerGrantType
+}
+
+func (f *JWTBearerFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Request) {
+	assertion := r.FormValue("assertion")
+	if assertion == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// Client authentication is optional, but must name the assertion issuer
+	client := tokenClient(r)
+	if client.ID != assertionIssuer(r) {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if !checkDPoPPolicy(w, r, client) {
+		return
+	}
+
+	claims, err := verifyClientJWT(client, assertion, r)
+	if err != nil {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := ResolveScopes(client, r.FormValue("scope"))
+	if err == ErrInvalidScope {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	// A client may only assert a user's identity when an admin allowed it,
+	// and with that user's consent
+	if subject != client.ID {
+		if !client.JWTBearerUserAssertions {
+			http.Error(w, "unauthorized_client", http.StatusBadRequest)
+			return
+		}
+		if _, err := LookupUser(subject); err != nil {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		required, err := ConsentRequired(client, subject, scope)
+		if err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+		if required {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+	}
+
+	accessToken, err := GenerateAccessToken(subject, client, scope, tokenBinding(r))
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	token := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   tokenBinding(r).tokenType(),
+		"expires_in":   int(AccessTokenLifetime(client).Seconds()),
+		"scope":        scope,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(token)
+}
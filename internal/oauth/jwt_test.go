@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"zenauth/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// useSigningKey makes a fresh key the active signing key for the test
+func useSigningKey(t *testing.T, alg string) *SigningKey {
+	t.Helper()
+
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey(%s): %v", alg, err)
+	}
+
+	previous := signingKeys
+	signingKeys = &keySet{keys: map[string]*SigningKey{key.ID: key}, active: key}
+	t.Cleanup(func() { signingKeys = previous })
+	return key
+}
+
+// signWith signs claims with key, setting the typ header when not empty
+func signWith(t *testing.T, key *SigningKey, claims jwt.MapClaims, typ string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	config.App.JWTSecret = "test-secret"
+	config.App.Revocation.DenylistAccessTokens = false
+
+	key := useSigningKey(t, AlgES256)
+	other, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+
+	claims := func(aud string, extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "user-1",
+			"aud":   aud,
+			"scope": "read",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(accessTokenAudience, nil))
+	hmacToken.Header["typ"] = accessTokenJWTType
+	hmacSigned, err := hmacToken.SignedString([]byte(config.App.JWTSecret))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error // nil when any error is expected, see valid
+		valid   bool
+	}{
+		{
+			name:  "access token",
+			token: signWith(t, key, claims(accessTokenAudience, nil), accessTokenJWTType),
+			valid: true,
+		},
+		{
+			name:    "exchanged token for another audience",
+			token:   signWith(t, key, claims("billing-api", jwt.MapClaims{"act": map[string]interface{}{"sub": "orders"}}), accessTokenJWTType),
+			wantErr: ErrNotAccessToken,
+		},
+		{
+			name:    "token for another audience",
+			token:   signWith(t, key, claims("billing-api", nil), accessTokenJWTType),
+			wantErr: ErrNotAccessToken,
+		},
+		{
+			name:    "ID token",
+			token:   signWith(t, key, claims("demo-client", nil), ""),
+			wantErr: ErrNotAccessToken,
+		},
+		{
+			name:    "ID token addressed to ZenAuth",
+			token:   signWith(t, key, claims(accessTokenAudience, nil), ""),
+			wantErr: ErrNotAccessToken,
+		},
+		{
+			name:    "logout token",
+			token:   signWith(t, key, claims(accessTokenAudience, nil), logoutTokenJWTType),
+			wantErr: ErrNotAccessToken,
+		},
+		{
+			name:  "expired",
+			token: signWith(t, key, claims(accessTokenAudience, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), accessTokenJWTType),
+		},
+		{
+			name:  "unknown key",
+			token: signWith(t, other, claims(accessTokenAudience, nil), accessTokenJWTType),
+		},
+		{
+			name:  "shared secret while a key is active",
+			token: hmacSigned,
+		},
+		{
+			name:  "malformed",
+			token: "not-a-jwt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateAccessToken(tt.token)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("ValidateAccessToken() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ValidateAccessToken() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
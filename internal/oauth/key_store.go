@@ -0,0 +1,265 @@
+package oauth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+// How often the rotation schedule is checked and keys are reloaded from the database
+const keyRotationCheckInterval = time.Minute
+
+// Retired keys keep validating tokens until the last token they signed has expired
+const retiredKeyGracePeriod = maxAccessTokenLifetime
+
+// ErrKeyRotationUnavailable is returned when keys are not managed in the database
+var ErrKeyRotationUnavailable = errors.New("key rotation requires database-managed signing keys")
+
+// persistentKeysEnabled reports whether signing keys are stored in Postgres
+func persistentKeysEnabled() bool {
+	return config.App.Signing.Algorithm != AlgHS256 && config.App.Signing.KeyFile == ""
+}
+
+// initKeyStore loads the signing keys, creating the first one. Like every
+// rotation, it holds the rotation lock shared by all instances, so that
+// instances starting together do not each create a key
+func initKeyStore() error {
+	return repositories.WithSigningKeyRotationLock(func() error {
+		if err := reloadSigningKeys(); err != nil {
+			return err
+		}
+
+		if signingKeys.current() == nil {
+			if _, err := rotateSigningKey(true); err != nil {
+				return fmt.Errorf("failed to create initial signing key: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RotateSigningKey generates a new signing key. The key is published in the
+// JWKS right away; unless immediate is set it only starts signing tokens once
+// the publish delay has elapsed, so resource servers can refresh their cache.
+// Rotations started by the scheduler and the admin API of any instance are
+// serialised by a Postgres advisory lock
+func RotateSigningKey(immediate bool) (*models.SigningKey, error) {
+	if !persistentKeysEnabled() {
+		return nil, ErrKeyRotationUnavailable
+	}
+
+	var record *models.SigningKey
+	err := repositories.WithSigningKeyRotationLock(func() error {
+		var err error
+		record, err = rotateSigningKey(immediate)
+		return err
+	})
+	return record, err
+}
+
+// rotateSigningKey creates the key, the rotation lock being held
+func rotateSigningKey(immediate bool) (*models.SigningKey, error) {
+	key, err := GenerateSigningKey(config.App.Signing.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	pemData, err := key.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptKeyMaterial(pemData)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encrypted,
+		State:      models.SigningKeyPending,
+		CreatedAt:  time.Now(),
+	}
+	if err := repositories.CreateSigningKey(record); err != nil {
+		return nil, err
+	}
+
+	if immediate {
+		if err := activateSigningKey(record.ID); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		record.State = models.SigningKeyActive
+		record.ActivatedAt = &now
+	}
+
+	log.Printf("🔑 New signing key %s created (%s)", record.ID, record.State)
+	return record, reloadSigningKeys()
+}
+
+// ListSigningKeys returns the metadata of every key still able to validate tokens
+func ListSigningKeys() ([]models.SigningKey, error) {
+	if !persistentKeysEnabled() {
+		return nil, ErrKeyRotationUnavailable
+	}
+	return repositories.GetSigningKeys()
+}
+
+// RunKeyRotation runs the rotation schedule until ctx is cancelled
+func RunKeyRotation(ctx context.Context) {
+	if !persistentKeysEnabled() {
+		return
+	}
+
+	ticker := time.NewTicker(keyRotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkKeyRotation(); err != nil {
+				log.Printf("Signing key rotation error: %v", err)
+			}
+		}
+	}
+}
+
+// checkKeyRotation promotes pending keys whose publish delay has elapsed,
+// creates a pending key when the active one is due for rotation and purges
+// retired keys that can no longer validate any token
+func checkKeyRotation() error {
+	return repositories.WithSigningKeyRotationLock(rotateDueSigningKeys)
+}
+
+// rotateDueSigningKeys applies the rotation schedule, the rotation lock being
+// held so that the keys it reads are not changed by another instance
+func rotateDueSigningKeys() error {
+	records, err := repositories.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	var active, pending *models.SigningKey
+	for i := range records {
+		switch records[i].State {
+		case models.SigningKeyActive:
+			active = &records[i]
+		case models.SigningKeyPending:
+			// Records are ordered newest first
+			if pending == nil {
+				pending = &records[i]
+			}
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case pending != nil && now.Sub(pending.CreatedAt) >= config.App.Signing.PublishDelay:
+		if err := activateSigningKey(pending.ID); err != nil {
+			return err
+		}
+		log.Printf("🔑 Signing key %s is now active", pending.ID)
+	case pending == nil && (active == nil || active.ActivatedAt == nil ||
+		now.Sub(*active.ActivatedAt) >= config.App.Signing.RotationInterval):
+		if _, err := rotateSigningKey(active == nil); err != nil {
+			return err
+		}
+	}
+
+	if deleted, err := repositories.DeleteExpiredSigningKeys(); err != nil {
+		log.Printf("Failed to delete expired signing keys: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d expired signing keys", deleted)
+	}
+
+	return reloadSigningKeys()
+}
+
+func activateSigningKey(kid string) error {
+	return repositories.ActivateSigningKey(kid, time.Now().Add(retiredKeyGracePeriod))
+}
+
+// reloadSigningKeys refreshes the in-memory key set from the database, so
+// every instance picks up keys rotated elsewhere
+func reloadSigningKeys() error {
+	records, err := repositories.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey, len(records))
+	var active *SigningKey
+	for _, record := range records {
+		pemData, err := decryptKeyMaterial(record.PrivateKey)
+		if err != nil {
+			log.Printf("Failed to decrypt signing key %s: %v", record.ID, err)
+			continue
+		}
+
+		key, err := ParseSigningKey(record.Algorithm, pemData)
+		if err != nil {
+			log.Printf("Failed to parse signing key %s: %v", record.ID, err)
+			continue
+		}
+		key.ExpiresAt = record.ExpiresAt
+
+		keys[key.ID] = key
+		if record.State == models.SigningKeyActive {
+			active = key
+		}
+	}
+
+	signingKeys.replace(keys, active)
+	return nil
+}
+
+// encryptKeyMaterial seals a private key with AES-256-GCM before it is stored
+func encryptKeyMaterial(plaintext []byte) ([]byte, error) {
+	gcm, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decryptKeyMaterial(ciphertext []byte) ([]byte, error) {
+	gcm, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("encrypted key too short")
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func keyEncryptionCipher() (cipher.AEAD, error) {
+	secret := sha256.Sum256([]byte(config.App.Signing.EncryptionKey))
+	block, err := aes.NewCipher(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
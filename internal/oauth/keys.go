@@ -0,0 +1,295 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+	"zenauth/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Supported token signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is an asymmetric key used to sign tokens, identified by its kid
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	ExpiresAt  *time.Time // Set once the key is retired
+}
+
+// keySet holds the keys accepted for token validation and the one used for signing
+type keySet struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+var signingKeys = &keySet{keys: map[string]*SigningKey{}}
+
+// InitSigningKeys loads the signing keys configured in config.App.Signing.
+// Keys are read from SIGNING_KEY_FILE when set (generated on first start),
+// otherwise they are managed in Postgres and rotated by RunKeyRotation
+func InitSigningKeys() error {
+	alg := config.App.Signing.Algorithm
+	if alg == AlgHS256 {
+		log.Println("⚠️ Tokens are signed with HS256 using the shared JWT secret")
+		return nil
+	}
+
+	if !persistentKeysEnabled() {
+		key, err := loadOrGenerateSigningKey(alg, config.App.Signing.KeyFile)
+		if err != nil {
+			return err
+		}
+
+		signingKeys.replace(map[string]*SigningKey{key.ID: key}, key)
+		log.Printf("✅ Token signing key loaded from file (alg: %s, kid: %s)", key.Algorithm, key.ID)
+		return nil
+	}
+
+	if err := initKeyStore(); err != nil {
+		return err
+	}
+
+	key := signingKeys.current()
+	log.Printf("✅ Token signing keys loaded from database (alg: %s, active kid: %s)", key.Algorithm, key.ID)
+	return nil
+}
+
+func loadOrGenerateSigningKey(alg, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ParseSigningKey(alg, data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = key.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	log.Printf("🔑 Generated new signing key at %s", path)
+	return key, nil
+}
+
+// GenerateSigningKey creates a new private key for the given algorithm
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var priv crypto.Signer
+	var err error
+
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(alg, priv)
+}
+
+// ParseSigningKey decodes a PEM-encoded private key (PKCS#8, PKCS#1 or SEC 1)
+func ParseSigningKey(alg string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM signing key")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	priv, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported signing key type")
+	}
+
+	return newSigningKey(alg, priv)
+}
+
+func newSigningKey(alg string, priv crypto.Signer) (*SigningKey, error) {
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		if alg != AlgRS256 {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+	case *ecdsa.PrivateKey:
+		if alg != AlgES256 || k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA key cannot be used with %s", alg)
+		}
+	case ed25519.PrivateKey:
+		if alg != AlgEdDSA {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+	default:
+		return nil, errors.New("unsupported signing key type")
+	}
+
+	key := &SigningKey{Algorithm: alg, PrivateKey: priv}
+	kid, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+	return key, nil
+}
+
+// MarshalPEM encodes the private key as a PKCS#8 PEM block
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicJWK returns the public part of the key as a JSON Web Key
+func (k *SigningKey) PublicJWK() map[string]interface{} {
+	jwk := k.publicMembers()
+	jwk["kid"] = k.ID
+	jwk["alg"] = k.Algorithm
+	jwk["use"] = "sig"
+	return jwk
+}
+
+// publicMembers returns the required JWK members of the public key (RFC 7638)
+func (k *SigningKey) publicMembers() map[string]interface{} {
+	enc := base64.RawURLEncoding.EncodeToString
+
+	switch pub := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]interface{}{
+			"kty": "RSA",
+			"n":   enc(pub.N.Bytes()),
+			"e":   enc(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return map[string]interface{}{
+			"kty": "EC",
+			"crv": pub.Curve.Params().Name,
+			"x":   enc(pub.X.FillBytes(make([]byte, size))),
+			"y":   enc(pub.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   enc(pub),
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, used as the kid
+func (k *SigningKey) thumbprint() (string, error) {
+	// encoding/json sorts map keys, which gives the canonical member order
+	data, err := json.Marshal(k.publicMembers())
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(h[:]), nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// replace swaps the whole key set, used when keys are reloaded from storage
+func (s *keySet) replace(keys map[string]*SigningKey, active *SigningKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.active = active
+}
+
+func (s *keySet) current() *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+func (s *keySet) get(kid string) *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key := s.keys[kid]
+	if key == nil || key.expired() {
+		return nil
+	}
+	return key
+}
+
+func (k *SigningKey) expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// JWKS returns the public signing keys as a JSON Web Key Set
+func JWKS() map[string]interface{} {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	keys := make([]map[string]interface{}, 0, len(signingKeys.keys))
+	for _, key := range signingKeys.keys {
+		if !key.expired() {
+			keys = append(keys, key.PublicJWK())
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// SigningAlgorithm returns the algorithm currently used to sign tokens
+func SigningAlgorithm() string {
+	if key := signingKeys.current(); key != nil {
+		return key.Algorithm
+	}
+	return AlgHS256
+}
@@ -0,0 +1,48 @@
+package oauth
+
+import (
+	"errors"
+	"zenauth/config"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidIDTokenHint = errors.New("invalid id_token_hint")
+
+// ParseIDTokenHint verifies an ID token previously issued by this server and
+// returns its subject and audience. Expired tokens are accepted, as RPs
+// usually log out with the last ID token they received. Access tokens and
+// logout tokens, signed with the same keys, are rejected
+func ParseIDTokenHint(idToken string) (subject, clientID string, err error) {
+	token, err := jwt.Parse(idToken, verificationKey)
+	if err != nil {
+		ve, ok := err.(*jwt.ValidationError)
+		if !ok || ve.Errors != jwt.ValidationErrorExpired {
+			return "", "", ErrInvalidIDTokenHint
+		}
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ == logoutTokenJWTType || typ == accessTokenJWTType {
+		return "", "", ErrInvalidIDTokenHint
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(config.App.Issuer, true) {
+		return "", "", ErrInvalidIDTokenHint
+	}
+	if _, ok := claims["events"]; ok {
+		return "", "", ErrInvalidIDTokenHint
+	}
+
+	// ID tokens are addressed to the client they were issued to
+	subject, _ = claims["sub"].(string)
+	clientID, _ = claims["aud"].(string)
+	if subject == "" || clientID == "" || clientID == accessTokenAudience {
+		return "", "", ErrInvalidIDTokenHint
+	}
+	if _, err := repositories.GetClientByID(clientID); err != nil {
+		return "", "", ErrInvalidIDTokenHint
+	}
+	return subject, clientID, nil
+}
@@ -0,0 +1,173 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const (
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	logoutTokenLifetime    = 2 * time.Minute
+	logoutRetryDelay       = time.Second
+
+	// logoutTokenJWTType is the typ header of logout tokens, which are never
+	// accepted as ID tokens or access tokens
+	logoutTokenJWTType = "logout+jwt"
+)
+
+// logoutDeliveries tracks the back-channel logout deliveries running in the
+// background, so that shutdown waits for them
+var logoutDeliveries = newLogoutDeliveryGroup()
+
+type logoutDeliveryGroup struct {
+	mu       sync.Mutex
+	stopping bool
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func newLogoutDeliveryGroup() *logoutDeliveryGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &logoutDeliveryGroup{ctx: ctx, cancel: cancel}
+}
+
+// LogoutUser ends every SSO session of a user and returns the clients the
+// user was signed in to, which should be notified
+func LogoutUser(userID string) ([]models.Client, error) {
+	if _, err := repositories.DeleteUserSSOSessions(userID); err != nil {
+		return nil, err
+	}
+	return repositories.GetUserSignedInClients(userID)
+}
+
+// GenerateLogoutToken creates a logout token (OIDC Back-Channel Logout 1.0)
+// telling a client that the user's sessions are over
+func GenerateLogoutToken(subject, clientID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":    config.App.Issuer,
+		"aud":    clientID,
+		"sub":    subject,
+		"iat":    now.Unix(),
+		"exp":    now.Add(logoutTokenLifetime).Unix(),
+		"jti":    uuid.NewString(),
+		"events": map[string]interface{}{backchannelLogoutEvent: map[string]interface{}{}},
+	}
+	return signTypedToken(claims, logoutTokenJWTType)
+}
+
+// NotifyBackchannelLogout POSTs a logout token to every client with a
+// back-channel logout URI. Deliveries run in the background and are retried
+// until StopLogoutNotifications
+func NotifyBackchannelLogout(userID string, clients []models.Client) {
+	g := logoutDeliveries
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, client := range clients {
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+		if g.stopping {
+			log.Printf("⚠️ Shutting down, back-channel logout of user %s not sent to client %s", userID, client.ID)
+			continue
+		}
+		g.wg.Add(1)
+		go func(client models.Client) {
+			defer g.wg.Done()
+			deliverLogoutToken(g.ctx, client, userID)
+		}(client)
+	}
+}
+
+// StopLogoutNotifications refuses new deliveries and waits for the running
+// ones. Those still running when ctx is done are abandoned
+func StopLogoutNotifications(ctx context.Context) error {
+	g := logoutDeliveries
+	g.mu.Lock()
+	g.stopping = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		g.cancel()
+		<-done
+		return fmt.Errorf("back-channel logout deliveries abandoned: %w", ctx.Err())
+	}
+}
+
+func deliverLogoutToken(ctx context.Context, client models.Client, userID string) {
+	// URIs of dynamically registered clients must not reach the server's network
+	httpClient := &http.Client{Timeout: config.App.Logout.BackchannelTimeout}
+	if client.RegistrationAccessTokenHash != "" {
+		httpClient = newOutboundClient(config.App.Logout.BackchannelTimeout)
+	}
+	delay := logoutRetryDelay
+
+	for attempt := 1; attempt <= config.App.Logout.BackchannelAttempts; attempt++ {
+		// A fresh token per attempt, so that retries are not rejected as replays
+		token, err := GenerateLogoutToken(userID, client.ID)
+		if err != nil {
+			log.Printf("❌ Failed to create logout token for client %s: %v", client.ID, err)
+			return
+		}
+
+		form := url.Values{"logout_token": {token}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelLogoutURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			log.Printf("❌ Invalid back-channel logout URI for client %s: %v", client.ID, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				log.Printf("Back-channel logout of user %s delivered to client %s", userID, client.ID)
+				return
+			}
+			log.Printf("⚠️ Back-channel logout to client %s failed (attempt %d): HTTP %d", client.ID, attempt, resp.StatusCode)
+		} else {
+			log.Printf("⚠️ Back-channel logout to client %s failed (attempt %d): %v", client.ID, attempt, err)
+		}
+
+		// A 400 means the client rejected the token, retrying will not help
+		if resp != nil && resp.StatusCode == http.StatusBadRequest {
+			return
+		}
+
+		if attempt < config.App.Logout.BackchannelAttempts {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				log.Printf("❌ Back-channel logout of user %s to client %s interrupted by shutdown", userID, client.ID)
+				return
+			}
+			delay *= 2
+		}
+	}
+
+	log.Printf("❌ Giving up back-channel logout of user %s to client %s", userID, client.ID)
+}
@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"time"
+	"zenauth/config"
+	uProviders "zenauth/internal/adapters/users"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const idTokenLifetime = time.Hour
+
+// StandardScopes are the OpenID Connect scopes understood by ZenAuth
+var StandardScopes = []string{"openid", "profile", "email"}
+
+// GenerateIDToken creates a signed OpenID Connect ID token for the given user
+func GenerateIDToken(user *models.User, clientID, nonce, scope, accessToken string, authTime time.Time) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       config.App.Issuer,
+		"aud":       clientID,
+		"exp":       now.Add(idTokenLifetime).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": authTime.Unix(),
+	}
+
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	if accessToken != "" {
+		claims["at_hash"] = accessTokenHash(accessToken)
+	}
+
+	for k, v := range UserClaims(user, scope) {
+		claims[k] = v
+	}
+
+	return signToken(claims)
+}
+
+// UserClaims returns the standard OIDC claims for a user, filtered by the granted scope
+func UserClaims(user *models.User, scope string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": user.ID,
+	}
+
+	if HasScope(scope, "profile") {
+		claims["preferred_username"] = user.Username
+	}
+
+	if HasScope(scope, "email") && user.Email != "" {
+		claims["email"] = user.Email
+	}
+
+	return claims
+}
+
+// HasScope reports whether a space-delimited scope string contains the given value
+func HasScope(scope, value string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupUser finds a user by ID, first through the configured user provider
+// and then in the local database
+func LookupUser(id string) (*models.User, error) {
+	if uProviders.CurrentUserProvider != nil {
+		if user, err := uProviders.CurrentUserProvider.GetUserByID(id); err == nil {
+			return user, nil
+		}
+	}
+	return repositories.GetUserByID(id)
+}
+
+// accessTokenHash computes the at_hash claim: the base64url encoding of the
+// left-most half of the hash of the access token, using the hash function
+// of the ID token signing algorithm
+func accessTokenHash(accessToken string) string {
+	var h []byte
+	if SigningAlgorithm() == AlgEdDSA {
+		sum := sha512.Sum512([]byte(accessToken))
+		h = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(accessToken))
+		h = sum[:]
+	}
+	return base64.RawURLEncoding.EncodeToString(h[:len(h)/2])
+}
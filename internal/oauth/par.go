@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+// RequestURIPrefix starts the request_uri values issued by the PAR endpoint (RFC 9126)
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+var ErrInvalidRequestURI = errors.New("invalid request_uri")
+
+// Client authentication parameters are not part of the authorization request
+var clientAuthParams = []string{"client_secret", "client_assertion", "client_assertion_type"}
+
+// AuthenticatePushingClient authenticates the client pushing an authorization
+// request. Confidential clients must send credentials, public clients are
+// identified by their client_id
+func AuthenticatePushingClient(r *http.Request) (*models.Client, error) {
+	if hasClientCredentials(r) {
+		return AuthenticateClient(r)
+	}
+
+	client, err := IdentifyClient(r)
+	if err != nil {
+		return nil, err
+	}
+	if !client.IsPublic() {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// PushAuthorizationRequest stores the authorization request parameters of
+// the client, referenced by a new single-use request_uri
+func PushAuthorizationRequest(client *models.Client, params url.Values) (*models.PushedAuthorizationRequest, error) {
+	stored := url.Values{}
+	for name, values := range params {
+		stored[name] = values
+	}
+	for _, name := range clientAuthParams {
+		stored.Del(name)
+	}
+	stored.Set("client_id", client.ID)
+
+	pushed := &models.PushedAuthorizationRequest{
+		RequestURI: RequestURIPrefix + generateRandomToken(),
+		ClientID:   client.ID,
+		Parameters: stored.Encode(),
+		ExpiresAt:  time.Now().Add(config.App.PushedAuthorization.Lifetime),
+	}
+	if err := repositories.StorePushedAuthorizationRequest(pushed); err != nil {
+		return nil, err
+	}
+	return pushed, nil
+}
+
+// ResolvePushedAuthorizationRequest returns the parameters the client pushed
+// under requestURI. They replace the query parameters of the authorization
+// request entirely
+func ResolvePushedAuthorizationRequest(requestURI, clientID string) (url.Values, error) {
+	pushed, err := repositories.GetPushedAuthorizationRequest(requestURI)
+	if err != nil || pushed.ClientID != clientID {
+		return nil, ErrInvalidRequestURI
+	}
+
+	params, err := url.ParseQuery(pushed.Parameters)
+	if err != nil {
+		return nil, ErrInvalidRequestURI
+	}
+	params.Set("request_uri", requestURI)
+	return params, nil
+}
+
+// ConsumePushedAuthorizationRequest ends the use of a request_uri once the
+// user was authenticated for it
+func ConsumePushedAuthorizationRequest(requestURI string) error {
+	return repositories.DeletePushedAuthorizationRequest(requestURI)
+}
+
+// PushedAuthorizationRequired reports whether the client may only send its
+// authorization requests through the PAR endpoint
+func PushedAuthorizationRequired(client *models.Client) bool {
+	return config.App.PushedAuthorization.Required || client.RequirePushedAuthorizationRequests
+}
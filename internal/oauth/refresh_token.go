@@ -2,8 +2,16 @@ package oauth
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
 	"zenauth/internal/repositories"
+
+	"github.com/google/uuid"
 )
 
 type RefreshTokenFlow struct{}
@@ -24,20 +32,83 @@ func (f *RefreshTokenFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	clientID, userID, err := repositories.GetRefreshToken(refreshToken)
+	stored, err := repositories.GetRefreshToken(refreshToken)
 	if err != nil {
+		detectRefreshTokenReuse(refreshToken)
 		http.Error(w, "invalid_grant", http.StatusBadRequest)
 		return
 	}
+	clientID := stored.ClientID
+
+	// The token may only be refreshed by the client it was issued to
+	client := tokenClient(r)
+	if client.ID != clientID {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if !checkDPoPPolicy(w, r, client) {
+		return
+	}
+
+	// Enforce the absolute lifetime and the idle timeout
+	if refreshTokenExpired(client, stored, time.Now()) {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	// A token bound to a DPoP key is only refreshed with a proof of that key
+	binding := tokenBinding(r)
+	if stored.DPoPJKT != "" && stored.DPoPJKT != binding.JKT {
+		http.Error(w, "invalid_dpop_proof", http.StatusBadRequest)
+		return
+	}
+
+	// The original grant's scope may be narrowed but never widened
+	granted, err := refreshTokenScope(client, stored)
+	if err == ErrInvalidScope {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	scope, err := NarrowScopes(granted, r.FormValue("scope"))
+	if err != nil {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
 
 	var subject string
-	if userID != nil {
-		subject = *userID
+	if stored.UserID != nil {
+		subject = *stored.UserID
 	} else {
 		subject = clientID
 	}
 
-	accessToken, err := GenerateAccessToken(subject, "default")
+	// Rotate: the presented token is invalidated and replaced by a new one
+	newRefreshToken := generateRandomToken()
+	err = repositories.RotateRefreshToken(refreshToken, &models.RefreshToken{
+		Token:     newRefreshToken,
+		ClientID:  clientID,
+		UserID:    stored.UserID,
+		FamilyID:  stored.FamilyID,
+		Scope:     granted,
+		ExpiresAt: stored.ExpiresAt,
+		DPoPJKT:   stored.DPoPJKT,
+	})
+	if errors.Is(err, repositories.ErrRefreshTokenReused) {
+		// Lost a race with another use of the same token
+		revokeRefreshTokenFamily(stored, "refresh token used concurrently")
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := GenerateAccessToken(subject, client, scope, binding)
 	if err != nil {
 		http.Error(w, "server_error", http.StatusInternalServerError)
 		return
@@ -45,11 +116,104 @@ func (f *RefreshTokenFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Req
 
 	resp := map[string]interface{}{
 		"access_token":  accessToken,
-		"token_type":    "bearer",
-		"expires_in":    3600,
-		"refresh_token": refreshToken,
+		"token_type":    binding.tokenType(),
+		"expires_in":    int(AccessTokenLifetime(client).Seconds()),
+		"refresh_token": newRefreshToken,
+		"scope":         scope,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// issueRefreshToken stores the first refresh token of a new rotation family,
+// whose absolute lifetime starts now. Clients that may not use the
+// refresh_token grant get none, and an empty token is returned
+func issueRefreshToken(client *models.Client, userID *string, scope string, binding TokenBinding) (string, error) {
+	if !GrantAllowed(client, "refresh_token") {
+		return "", nil
+	}
+
+	rt := &models.RefreshToken{
+		Token:    generateRandomToken(),
+		ClientID: client.ID,
+		UserID:   userID,
+		FamilyID: uuid.NewString(),
+		Scope:    scope,
+		DPoPJKT:  binding.JKT,
+	}
+
+	if lifetime := refreshTokenLifetime(client); lifetime > 0 {
+		expiresAt := time.Now().Add(lifetime)
+		rt.ExpiresAt = &expiresAt
+	}
+
+	return rt.Token, repositories.StoreRefreshToken(rt)
+}
+
+// refreshTokenExpired reports whether a refresh token is past its absolute
+// lifetime or was left unused for longer than the client's idle timeout
+func refreshTokenExpired(client *models.Client, stored *models.RefreshToken, now time.Time) bool {
+	if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+		return true
+	}
+	idle := refreshTokenIdleTimeout(client)
+	return idle > 0 && now.Sub(stored.LastActivity()) > idle
+}
+
+// refreshTokenScope returns the scope granted with a refresh token. Tokens
+// issued before scopes were tracked are stored with an empty scope, and get
+// the default scope (DEFAULT_SCOPE, seeded as "basic")
+func refreshTokenScope(client *models.Client, stored *models.RefreshToken) (string, error) {
+	if stored.Scope != "" {
+		return stored.Scope, nil
+	}
+	return ResolveScopes(client, "")
+}
+
+// refreshTokenLifetime returns the absolute lifetime of the client's refresh tokens
+func refreshTokenLifetime(client *models.Client) time.Duration {
+	if client.RefreshTokenLifetime > 0 {
+		return time.Duration(client.RefreshTokenLifetime) * time.Second
+	}
+	return config.App.RefreshToken.Lifetime
+}
+
+// refreshTokenIdleTimeout returns how long the client's refresh tokens may stay unused
+func refreshTokenIdleTimeout(client *models.Client) time.Duration {
+	if client.RefreshTokenIdleTimeout > 0 {
+		return time.Duration(client.RefreshTokenIdleTimeout) * time.Second
+	}
+	return config.App.RefreshToken.IdleTimeout
+}
+
+// detectRefreshTokenReuse revokes the whole family when a token that was
+// already rotated out is presented again: either the legitimate client or an
+// attacker holds a stolen copy, and we cannot tell which
+func detectRefreshTokenReuse(token string) {
+	rotated, err := repositories.GetRotatedRefreshToken(token)
+	if err != nil {
+		return
+	}
+	revokeRefreshTokenFamily(rotated, "rotated refresh token presented again")
+}
+
+func revokeRefreshTokenFamily(rt *models.RefreshToken, reason string) {
+	revoked, err := repositories.RevokeRefreshTokenFamily(rt.FamilyID)
+	if err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", rt.FamilyID, err)
+	}
+
+	log.Printf("⚠️ Refresh token reuse detected for client %s (family %s): %s, %d tokens revoked",
+		rt.ClientID, rt.FamilyID, reason, revoked)
+
+	err = repositories.RecordAuditEvent(&models.AuditEvent{
+		Type:     models.AuditRefreshTokenReuse,
+		ClientID: rt.ClientID,
+		UserID:   rt.UserID,
+		Details:  fmt.Sprintf("family %s: %s", rt.FamilyID, reason),
+	})
+	if err != nil {
+		log.Printf("Failed to record audit event: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package oauth
+
+import (
+	"testing"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+)
+
+func TestRefreshTokenScope(t *testing.T) {
+	previous := registeredScopes
+	registeredScopes = func() ([]models.Scope, error) {
+		return []models.Scope{{Name: "basic"}, {Name: "read"}, {Name: "write"}}, nil
+	}
+	t.Cleanup(func() {
+		registeredScopes = previous
+		config.App.DefaultScope = ""
+	})
+
+	readOnly := &models.Client{ID: "read-only", AllowedScopes: []string{"read"}}
+
+	tests := []struct {
+		name         string
+		defaultScope string
+		stored       string
+		want         string
+		wantErr      bool
+	}{
+		{name: "tracked scope", defaultScope: "basic", stored: "read write", want: "read write"},
+		{name: "legacy token gets the default scope", defaultScope: "basic", stored: "", want: "basic"},
+		{name: "legacy token without a default scope", defaultScope: "", stored: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.App.DefaultScope = tt.defaultScope
+
+			got, err := refreshTokenScope(readOnly, &models.RefreshToken{Scope: tt.stored})
+			if tt.wantErr {
+				if err != ErrInvalidScope {
+					t.Fatalf("refreshTokenScope() = %q, %v, want ErrInvalidScope", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("refreshTokenScope() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	config.App.RefreshToken.IdleTimeout = 0
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+
+	idleClient := &models.Client{ID: "idle", RefreshTokenIdleTimeout: 3600}
+	noIdleClient := &models.Client{ID: "no-idle"}
+
+	tests := []struct {
+		name   string
+		client *models.Client
+		stored *models.RefreshToken
+		want   bool
+	}{
+		{name: "fresh", client: idleClient, stored: &models.RefreshToken{IssuedAt: now, ExpiresAt: &future}},
+		{name: "no absolute lifetime", client: noIdleClient, stored: &models.RefreshToken{IssuedAt: lastWeek}},
+		{name: "past its lifetime", client: noIdleClient, stored: &models.RefreshToken{IssuedAt: lastWeek, ExpiresAt: &past}, want: true},
+		{name: "idle since issued", client: idleClient, stored: &models.RefreshToken{IssuedAt: lastWeek}, want: true},
+		{name: "used recently", client: idleClient, stored: &models.RefreshToken{IssuedAt: lastWeek, LastUsedAt: &past}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshTokenExpired(tt.client, tt.stored, now); got != tt.want {
+				t.Fatalf("refreshTokenExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+// RegistrationError is an error response of the registration endpoint (RFC 7591 section 3.2.2)
+type RegistrationError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *RegistrationError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func invalidMetadata(format string, args ...interface{}) error {
+	return &RegistrationError{Code: "invalid_client_metadata", Description: fmt.Sprintf(format, args...)}
+}
+
+func invalidRedirectURI(format string, args ...interface{}) error {
+	return &RegistrationError{Code: "invalid_redirect_uri", Description: fmt.Sprintf(format, args...)}
+}
+
+// ClientMetadata holds the client metadata ZenAuth accepts at registration
+type ClientMetadata struct {
+	RedirectURIs            []string        `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string        `json:"grant_types,omitempty"`
+	ResponseTypes           []string        `json:"response_types,omitempty"`
+	ClientName              string          `json:"client_name,omitempty"`
+	Scope                   string          `json:"scope,omitempty"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty"`
+	PostLogoutRedirectURIs  []string        `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string          `json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutURI   string          `json:"frontchannel_logout_uri,omitempty"`
+	LogoURI                 string          `json:"logo_uri,omitempty"`
+	ClientURI               string          `json:"client_uri,omitempty"`
+	PolicyURI               string          `json:"policy_uri,omitempty"`
+	TosURI                  string          `json:"tos_uri,omitempty"`
+
+	RequirePushedAuthorizationRequests bool     `json:"require_pushed_authorization_requests,omitempty"`
+	RequestURIs                        []string `json:"request_uris,omitempty"`
+	DPoPBoundAccessTokens              bool     `json:"dpop_bound_access_tokens,omitempty"`
+	TLSClientAuthSubjectDN             string   `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientCertificateThumbprints    []string `json:"tls_client_certificate_thumbprints,omitempty"`
+}
+
+// ApplyTo validates the metadata, fills in the defaults of RFC 7591 and
+// replaces the registered settings of the client with it
+func (m *ClientMetadata) ApplyTo(client *models.Client) error {
+	if len(m.GrantTypes) == 0 {
+		m.GrantTypes = []string{"authorization_code"}
+	}
+	if m.TokenEndpointAuthMethod == "" {
+		m.TokenEndpointAuthMethod = AuthMethodClientSecretBasic
+	}
+
+	if err := CheckGrantTypes(m.GrantTypes); err != nil {
+		return invalidMetadata("%v", err)
+	}
+
+	// Only the code response type exists, and it goes with the authorization code grant
+	usesCode := hasGrantType(m.GrantTypes, "authorization_code")
+	if len(m.ResponseTypes) == 0 && usesCode {
+		m.ResponseTypes = []string{"code"}
+	}
+	for _, responseType := range m.ResponseTypes {
+		if responseType != "code" {
+			return invalidMetadata("unsupported response type %q", responseType)
+		}
+	}
+	if usesCode != (len(m.ResponseTypes) > 0) {
+		return invalidMetadata("response_types and grant_types do not match")
+	}
+
+	if usesCode && len(m.RedirectURIs) == 0 {
+		return invalidRedirectURI("redirect_uris is required for the authorization_code grant")
+	}
+	// Native apps, which do not authenticate, may be redirected to their own scheme
+	native := m.TokenEndpointAuthMethod == AuthMethodNone
+	for _, uri := range m.RedirectURIs {
+		if err := checkRegisteredURI(uri, native); err != nil {
+			return invalidRedirectURI("redirect URI %q: %v", uri, err)
+		}
+	}
+	for _, uri := range m.PostLogoutRedirectURIs {
+		if err := checkRegisteredURI(uri, native); err != nil {
+			return invalidMetadata("post-logout redirect URI %q: %v", uri, err)
+		}
+	}
+	for _, uri := range []string{m.BackchannelLogoutURI, m.FrontchannelLogoutURI, m.LogoURI, m.ClientURI, m.PolicyURI, m.TosURI} {
+		if uri == "" {
+			continue
+		}
+		if err := checkRegisteredURI(uri, false); err != nil {
+			return invalidMetadata("URI %q: %v", uri, err)
+		}
+	}
+
+	// Request URIs may carry a fragment, used as a version of the request
+	// object. They and the back-channel logout URI are called by the server,
+	// and must not point into its own network
+	for _, uri := range m.RequestURIs {
+		if err := checkOutboundURI(uri); err != nil {
+			return invalidMetadata("request URI %q: %v", uri, err)
+		}
+	}
+	if m.BackchannelLogoutURI != "" {
+		if err := checkOutboundURI(m.BackchannelLogoutURI); err != nil {
+			return invalidMetadata("back-channel logout URI %q: %v", m.BackchannelLogoutURI, err)
+		}
+	}
+
+	if m.JWKSURI != "" {
+		return invalidMetadata("jwks_uri is not supported, register the keys in jwks")
+	}
+	var jwks string
+	if len(m.JWKS) > 0 && string(m.JWKS) != "null" {
+		jwks = string(m.JWKS)
+	}
+	if err := ValidateClientAuthSettings(m.TokenEndpointAuthMethod, jwks); err != nil {
+		return invalidMetadata("%v", err)
+	}
+	if err := ValidateClientCertificateSettings(m.TokenEndpointAuthMethod, m.TLSClientAuthSubjectDN, m.TLSClientCertificateThumbprints); err != nil {
+		return invalidMetadata("%v", err)
+	}
+	if m.TokenEndpointAuthMethod == AuthMethodNone {
+		for _, grantType := range confidentialGrantTypes {
+			if hasGrantType(m.GrantTypes, grantType) {
+				return invalidMetadata("public clients cannot use the %s grant", grantType)
+			}
+		}
+	}
+
+	// Registered clients always get an allow-list, or they could request any
+	// scope: without a scope in the metadata, they only get the default scope
+	if strings.TrimSpace(m.Scope) == "" {
+		m.Scope = config.App.DefaultScope
+	}
+	scopes := uniqueScopes(m.Scope)
+	if len(scopes) == 0 {
+		return invalidMetadata("scope is required")
+	}
+	for _, name := range scopes {
+		if _, err := repositories.GetScopeByName(name); err != nil {
+			return invalidMetadata("unknown scope %q", name)
+		}
+	}
+
+	// Clients that do not authenticate are public, and must use PKCE
+	client.Type = models.ClientConfidential
+	if m.TokenEndpointAuthMethod == AuthMethodNone {
+		client.Type = models.ClientPublic
+	}
+	client.Name = m.ClientName
+	client.RedirectURIs = m.RedirectURIs
+	client.TokenEndpointAuthMethod = m.TokenEndpointAuthMethod
+	client.GrantTypes = m.GrantTypes
+	client.AllowedScopes = scopes
+	client.JWKS = jwks
+	client.PostLogoutRedirectURIs = m.PostLogoutRedirectURIs
+	client.BackchannelLogoutURI = m.BackchannelLogoutURI
+	client.FrontchannelLogoutURI = m.FrontchannelLogoutURI
+	client.LogoURI = m.LogoURI
+	client.ClientURI = m.ClientURI
+	client.PolicyURI = m.PolicyURI
+	client.TosURI = m.TosURI
+	client.RequirePushedAuthorizationRequests = m.RequirePushedAuthorizationRequests
+	client.RequestURIs = m.RequestURIs
+	client.DPoPBoundAccessTokens = m.DPoPBoundAccessTokens
+	client.TLSClientAuthSubjectDN = m.TLSClientAuthSubjectDN
+	client.TLSClientCertificateThumbprints = m.TLSClientCertificateThumbprints
+	return nil
+}
+
+// ClientMetadataOf returns the registered metadata of a client
+func ClientMetadataOf(client *models.Client) ClientMetadata {
+	m := ClientMetadata{
+		RedirectURIs:            client.RedirectURIs,
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		GrantTypes:              client.GrantTypes,
+		ClientName:              client.Name,
+		Scope:                   strings.Join(client.AllowedScopes, " "),
+		PostLogoutRedirectURIs:  client.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:    client.BackchannelLogoutURI,
+		FrontchannelLogoutURI:   client.FrontchannelLogoutURI,
+		LogoURI:                 client.LogoURI,
+		ClientURI:               client.ClientURI,
+		PolicyURI:               client.PolicyURI,
+		TosURI:                  client.TosURI,
+
+		RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+		RequestURIs:                        client.RequestURIs,
+		DPoPBoundAccessTokens:              client.DPoPBoundAccessTokens,
+		TLSClientAuthSubjectDN:             client.TLSClientAuthSubjectDN,
+		TLSClientCertificateThumbprints:    client.TLSClientCertificateThumbprints,
+	}
+	if hasGrantType(client.GrantTypes, "authorization_code") {
+		m.ResponseTypes = []string{"code"}
+	}
+	// Keys registered by an admin may be PEM encoded, which has no metadata form
+	if strings.HasPrefix(strings.TrimSpace(client.JWKS), "{") {
+		m.JWKS = json.RawMessage(client.JWKS)
+	}
+	return m
+}
+
+// NewRegistrationAccessToken generates the token managing a dynamically
+// registered client. Only its hash is kept on the client
+func NewRegistrationAccessToken(client *models.Client) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	client.RegistrationAccessTokenHash = hashRegistrationAccessToken(token)
+	return token, nil
+}
+
+// VerifyRegistrationAccessToken checks the token managing a client
+func VerifyRegistrationAccessToken(client *models.Client, token string) bool {
+	if client.RegistrationAccessTokenHash == "" || token == "" {
+		return false
+	}
+	hash := hashRegistrationAccessToken(token)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(client.RegistrationAccessTokenHash)) == 1
+}
+
+// hashRegistrationAccessToken uses SHA-256 rather than bcrypt: the tokens
+// are random and long enough not to need key stretching
+func hashRegistrationAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkRegisteredURI accepts absolute URIs without fragment (RFC 6749 section
+// 3.1.2) using https, or http on a loopback address. Native apps may also use
+// private-use schemes, named after a domain they own (RFC 8252 section 7.1),
+// which rules out schemes such as javascript: or data:
+func checkRegisteredURI(uri string, native bool) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("must be an absolute URI")
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("must not contain a fragment")
+	}
+
+	switch {
+	case u.Scheme == "https" && u.Host != "":
+	case u.Scheme == "http" && isLoopbackHost(u.Hostname()):
+	case native && strings.Contains(u.Scheme, "."):
+	default:
+		return fmt.Errorf("must use https, http on a loopback address or, for native apps, a private-use scheme")
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether host names the local machine
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func hasGrantType(grantTypes []string, grantType string) bool {
+	for _, g := range grantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
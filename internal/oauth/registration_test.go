@@ -0,0 +1,34 @@
+package oauth
+
+import "testing"
+
+func TestCheckRegisteredURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		native  bool
+		wantErr bool
+	}{
+		{name: "https", uri: "https://app.example.com/callback"},
+		{name: "http on localhost", uri: "http://localhost:3000/callback"},
+		{name: "http on IPv4 loopback", uri: "http://127.0.0.1:3000/callback"},
+		{name: "http on IPv6 loopback", uri: "http://[::1]:3000/callback"},
+		{name: "private-use scheme of a native app", uri: "com.example.app:/callback", native: true},
+		{name: "private-use scheme of a web app", uri: "com.example.app:/callback", wantErr: true},
+		{name: "http on a public host", uri: "http://app.example.com/callback", wantErr: true},
+		{name: "https without host", uri: "https:/callback", wantErr: true},
+		{name: "javascript", uri: "javascript:alert(document.cookie)", native: true, wantErr: true},
+		{name: "data", uri: "data:text/html,<script>alert(1)</script>", native: true, wantErr: true},
+		{name: "relative", uri: "/callback", wantErr: true},
+		{name: "fragment", uri: "https://app.example.com/callback#top", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRegisteredURI(tt.uri, tt.native)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRegisteredURI(%q, %v) error = %v, wantErr %v", tt.uri, tt.native, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/models"
+)
+
+var (
+	ErrInvalidRequestObject = errors.New("invalid_request_object")
+	ErrRequestParamMismatch = errors.New("authorization parameter does not match the request object")
+)
+
+const (
+	// Bounds on fetching a request object by reference
+	requestObjectFetchTimeout = 5 * time.Second
+	maxRequestObjectSize      = 64 << 10
+)
+
+// authorizeParams are the authorization request parameters that may be sent
+// both inside a request object and in the query, where they must match
+var authorizeParams = []string{
+	"response_type", "client_id", "redirect_uri", "scope", "state", "nonce",
+	"prompt", "max_age", "code_challenge", "code_challenge_method",
+}
+
+// JWT claims of a request object that are not authorization parameters
+var requestObjectJWTClaims = map[string]bool{
+	"iss": true, "aud": true, "exp": true, "iat": true, "nbf": true, "jti": true,
+}
+
+// VerifyRequestObject verifies a request object signed by the client
+// (RFC 9101) and returns the authorization parameters it carries. Outer
+// parameters that are also authorization parameters must match the signed
+// ones; the signed values are the only ones used
+func VerifyRequestObject(client *models.Client, requestObject string, outer url.Values) (url.Values, error) {
+	claims, err := parseClientSignedJWT(client, requestObject)
+	if err != nil {
+		return nil, ErrInvalidRequestObject
+	}
+
+	// The object is addressed to this server by the client, and must expire
+	issuer := strings.TrimSuffix(config.App.Issuer, "/")
+	if !claims.VerifyIssuer(client.ID, true) ||
+		!(claims.VerifyAudience(issuer, true) || claims.VerifyAudience(issuer+"/", true)) {
+		return nil, ErrInvalidRequestObject
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Unix(int64(exp), 0).After(time.Now().Add(maxAssertionLifetime)) {
+		return nil, ErrInvalidRequestObject
+	}
+
+	params := url.Values{}
+	for name, value := range claims {
+		if requestObjectJWTClaims[name] {
+			continue
+		}
+		if name == "request" || name == "request_uri" {
+			return nil, ErrInvalidRequestObject
+		}
+		params.Set(name, requestObjectParam(value))
+	}
+	if clientID := params.Get("client_id"); clientID != "" && clientID != client.ID {
+		return nil, ErrInvalidRequestObject
+	}
+	params.Set("client_id", client.ID)
+
+	for _, name := range authorizeParams {
+		if value := outer.Get(name); value != "" && value != params.Get(name) {
+			return nil, ErrRequestParamMismatch
+		}
+	}
+
+	params.Set("request", requestObject)
+	return params, nil
+}
+
+// requestObjectParam converts a claim back to its parameter form: strings
+// as is, numbers in decimal and objects (such as claims) in JSON
+func requestObjectParam(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// FetchRequestObject retrieves the request object a client published at a
+// request_uri. Only https URIs the client registered are fetched, from public
+// addresses, so that the server cannot be pointed at its own network
+func FetchRequestObject(client *models.Client, requestURI string) (string, error) {
+	if !requestURIRegistered(client, requestURI) || checkOutboundURI(requestURI) != nil {
+		return "", ErrInvalidRequestURI
+	}
+
+	resp, err := newOutboundClient(requestObjectFetchTimeout).Get(requestURI)
+	if err != nil {
+		return "", ErrInvalidRequestURI
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrInvalidRequestURI
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestObjectSize))
+	if err != nil {
+		return "", ErrInvalidRequestURI
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// requestURIRegistered matches the request_uri, without its fragment, to the
+// ones registered by the client
+func requestURIRegistered(client *models.Client, requestURI string) bool {
+	uri, _, _ := strings.Cut(requestURI, "#")
+	for _, registered := range client.RequestURIs {
+		registered, _, _ = strings.Cut(registered, "#")
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package oauth
+
+import (
+	"errors"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrTokenRevoked         = errors.New("token has been revoked")
+	ErrTokenNotOwned        = errors.New("token was not issued to this client")
+	ErrUnsupportedTokenType = errors.New("unsupported_token_type")
+)
+
+// RevokeToken revokes a refresh or access token issued to the given client
+// (RFC 7009). Unknown or already invalid tokens are silently ignored
+func RevokeToken(token, tokenTypeHint, clientID string) error {
+	if tokenTypeHint == "access_token" {
+		if revoked, err := revokeAccessToken(token, clientID); revoked || err != nil {
+			return err
+		}
+		_, err := revokeRefreshToken(token, clientID)
+		return err
+	}
+
+	if revoked, err := revokeRefreshToken(token, clientID); revoked || err != nil {
+		return err
+	}
+	_, err := revokeAccessToken(token, clientID)
+	if errors.Is(err, ErrUnsupportedTokenType) && tokenTypeHint == "" {
+		// Not a refresh token and access tokens cannot be revoked: nothing to do
+		return nil
+	}
+	return err
+}
+
+func revokeRefreshToken(token, clientID string) (bool, error) {
+	stored, err := repositories.GetRefreshToken(token)
+	if err != nil {
+		return false, nil
+	}
+
+	if stored.ClientID != clientID {
+		return false, ErrTokenNotOwned
+	}
+
+	return true, repositories.RevokeRefreshToken(token)
+}
+
+func revokeAccessToken(token, clientID string) (bool, error) {
+	parsed, err := jwt.Parse(token, verificationKey)
+	if err != nil || !parsed.Valid {
+		return false, nil
+	}
+
+	if !config.App.Revocation.DenylistAccessTokens {
+		return false, ErrUnsupportedTokenType
+	}
+
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	if owner, _ := claims["client_id"].(string); owner != clientID {
+		return false, ErrTokenNotOwned
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" {
+		return false, nil
+	}
+
+	return true, repositories.RevokeAccessToken(jti, time.Unix(int64(exp), 0))
+}
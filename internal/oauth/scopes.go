@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"zenauth/config"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+)
+
+var ErrInvalidScope = errors.New("invalid_scope")
+
+// registeredScopes lists the scopes known to the server
+var registeredScopes = repositories.GetAllScopes
+
+// ResolveScopes validates the requested scopes for a client. Scopes that are
+// not registered or not allowed for the client are dropped; an empty request
+// is given the configured default scope, which every client may get, and
+// never more. Clients without an allowed-scopes list may request any
+// registered scope. ErrInvalidScope is returned when a request leaves
+// nothing to grant
+func ResolveScopes(client *models.Client, requested string) (string, error) {
+	registered, err := registeredScopes()
+	if err != nil {
+		return "", err
+	}
+	return resolveScopes(registered, client, requested)
+}
+
+// resolveScopes resolves the requested scopes against the registered ones
+func resolveScopes(registered []models.Scope, client *models.Client, requested string) (string, error) {
+	available := make(map[string]bool, len(registered))
+	for _, s := range registered {
+		available[s.Name] = true
+	}
+
+	if strings.TrimSpace(requested) == "" {
+		requested = config.App.DefaultScope
+	} else if len(client.AllowedScopes) > 0 {
+		allowed := make(map[string]bool, len(client.AllowedScopes))
+		for _, s := range client.AllowedScopes {
+			allowed[s] = available[s]
+		}
+		available = allowed
+	}
+
+	granted := []string{}
+	for _, s := range uniqueScopes(requested) {
+		if available[s] {
+			granted = append(granted, s)
+		}
+	}
+
+	if len(granted) == 0 {
+		return "", ErrInvalidScope
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// NarrowScopes checks that the requested scopes are a subset of the granted
+// ones (RFC 6749 section 6). An empty request keeps the granted scopes
+func NarrowScopes(granted, requested string) (string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return granted, nil
+	}
+
+	for _, s := range uniqueScopes(requested) {
+		if !HasScope(granted, s) {
+			return "", ErrInvalidScope
+		}
+	}
+	return strings.Join(uniqueScopes(requested), " "), nil
+}
+
+// SupportedScopes returns the names of the registered scopes
+func SupportedScopes() []string {
+	registered, err := repositories.GetAllScopes()
+	if err != nil {
+		return StandardScopes
+	}
+
+	names := make([]string, len(registered))
+	for i, s := range registered {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func uniqueScopes(scope string) []string {
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"testing"
+	"zenauth/config"
+	"zenauth/internal/models"
+)
+
+func TestResolveScopes(t *testing.T) {
+	registered := []models.Scope{{Name: "openid"}, {Name: "profile"}, {Name: "read"}, {Name: "write"}}
+	anyScope := &models.Client{ID: "any"}
+	readOnly := &models.Client{ID: "read-only", AllowedScopes: []string{"read", "unregistered"}}
+
+	tests := []struct {
+		name         string
+		client       *models.Client
+		defaultScope string
+		requested    string
+		want         string
+		wantErr      bool
+	}{
+		{name: "registered scopes", client: anyScope, requested: "read write", want: "read write"},
+		{name: "duplicates", client: anyScope, requested: "read read", want: "read"},
+		{name: "unknown scopes are dropped", client: anyScope, requested: "read admin", want: "read"},
+		{name: "only unknown scopes", client: anyScope, requested: "admin", wantErr: true},
+		{name: "not allowed for the client", client: readOnly, requested: "read write", want: "read"},
+		{name: "allowed but not registered", client: readOnly, requested: "unregistered", wantErr: true},
+		{name: "empty without default", client: anyScope, requested: "", wantErr: true},
+		{name: "empty with default", client: anyScope, defaultScope: "openid", requested: " ", want: "openid"},
+		{name: "default granted despite the allow-list", client: readOnly, defaultScope: "openid", requested: "", want: "openid"},
+		{name: "default not registered", client: anyScope, defaultScope: "admin", requested: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.App.DefaultScope = tt.defaultScope
+			t.Cleanup(func() { config.App.DefaultScope = "" })
+
+			got, err := resolveScopes(registered, tt.client, tt.requested)
+			if tt.wantErr {
+				if err != ErrInvalidScope {
+					t.Fatalf("resolveScopes() = %q, %v, want ErrInvalidScope", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveScopes() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNarrowScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		granted   string
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{name: "empty keeps the grant", granted: "read write", requested: "", want: "read write"},
+		{name: "subset", granted: "read write", requested: "read", want: "read"},
+		{name: "same scopes", granted: "read write", requested: "write read write", want: "write read"},
+		{name: "wider", granted: "read", requested: "read write", wantErr: true},
+		{name: "nothing granted", granted: "", requested: "read", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NarrowScopes(tt.granted, tt.requested)
+			if tt.wantErr {
+				if err != ErrInvalidScope {
+					t.Fatalf("NarrowScopes() = %q, %v, want ErrInvalidScope", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NarrowScopes() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"context"
+	"log"
+	"time"
+	"zenauth/config"
+	"zenauth/internal/repositories"
+)
+
+// RunSweeper periodically deletes expired refresh tokens, authorization
+// codes, consent requests, device codes, pushed authorization requests, SSO
+// sessions, access token denylist entries and used assertion jtis until ctx
+// is cancelled. A sweep in progress is completed before it returns
+func RunSweeper(ctx context.Context) {
+	interval := config.App.Sweeper.Interval
+	if interval <= 0 {
+		log.Println("Expired token sweeper is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepExpired()
+		}
+	}
+}
+
+func sweepExpired() {
+	if n, err := repositories.DeleteExpiredRefreshTokens(config.App.RefreshToken.IdleTimeout); err != nil {
+		log.Printf("Failed to delete expired refresh tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired refresh tokens", n)
+	}
+
+	if n, err := repositories.DeleteExpiredAuthCodes(); err != nil {
+		log.Printf("Failed to delete expired authorization codes: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired authorization codes", n)
+	}
+
+	if n, err := repositories.DeleteExpiredConsentRequests(); err != nil {
+		log.Printf("Failed to delete expired consent requests: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired consent requests", n)
+	}
+
+	if n, err := repositories.DeleteExpiredDeviceCodes(); err != nil {
+		log.Printf("Failed to delete expired device codes: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired device codes", n)
+	}
+
+	if n, err := repositories.DeleteExpiredPushedAuthorizationRequests(); err != nil {
+		log.Printf("Failed to delete expired pushed authorization requests: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired pushed authorization requests", n)
+	}
+
+	if n, err := repositories.DeleteExpiredSSOSessions(); err != nil {
+		log.Printf("Failed to delete expired SSO sessions: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired SSO sessions", n)
+	}
+
+	if n, err := repositories.DeleteExpiredRevokedAccessTokens(); err != nil {
+		log.Printf("Failed to delete expired access token denylist entries: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired access token denylist entries", n)
+	}
+
+	if n, err := repositories.DeleteExpiredAssertionJTIs(); err != nil {
+		log.Printf("Failed to delete expired assertion jtis: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired assertion jtis", n)
+	}
+
+	if n, err := repositories.DeleteExpiredDPoPProofJTIs(); err != nil {
+		log.Printf("Failed to delete expired DPoP proof jtis: %v", err)
+	} else if n > 0 {
+		log.Printf("🧹 Deleted %d expired DPoP proof jtis", n)
+	}
+}
@@ -0,0 +1,118 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"zenauth/internal/models"
+	"zenauth/internal/repositories"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidGrant = errors.New("invalid_grant")
+
+type tokenClientContextKey struct{}
+
+// WithTokenClient resolves the client making a token request, before it is
+// dispatched to a flow, and stores it in the request context. Confidential
+// clients are authenticated, public clients are identified by their client_id
+// or, without one, by the code or refresh token they present. A jwt-bearer
+// assertion authenticates its issuer, which the flow verifies
+func WithTokenClient(r *http.Request) (*http.Request, *models.Client, error) {
+	client, err := resolveTokenClient(r)
+	if err != nil {
+		return r, nil, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), tokenClientContextKey{}, client)), client, nil
+}
+
+// tokenClient returns the client resolved by WithTokenClient
+func tokenClient(r *http.Request) *models.Client {
+	client, _ := r.Context().Value(tokenClientContextKey{}).(*models.Client)
+	return client
+}
+
+func resolveTokenClient(r *http.Request) (*models.Client, error) {
+	clientID := r.PostFormValue("client_id")
+	if hasClientCredentials(r) {
+		client, err := AuthenticateClient(r)
+		if err != nil || (clientID != "" && clientID != client.ID) {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+
+	if clientID == "" {
+		var err error
+		if clientID, err = grantClientID(r); err != nil {
+			return nil, err
+		}
+		if clientID == "" {
+			return nil, ErrInvalidClient
+		}
+	}
+
+	client, err := repositories.GetClientByID(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	// The assertion is signed by its issuer, verified by the jwt-bearer flow
+	if r.PostFormValue("grant_type") == JWTBearerGrantType && client.ID == assertionIssuer(r) {
+		return client, nil
+	}
+
+	// Only public clients are identified by their client_id alone. This
+	// includes clients authenticating with their TLS certificate
+	if !client.IsPublic() {
+		authenticated, err := AuthenticateClient(r)
+		if err != nil || authenticated.ID != client.ID {
+			return nil, ErrInvalidClient
+		}
+	}
+	return client, nil
+}
+
+// grantClientID returns the client the code or refresh token of the request
+// was issued to, or the issuer of its jwt-bearer assertion. Rotated refresh
+// tokens still name their client, so that the flow detects their reuse
+func grantClientID(r *http.Request) (string, error) {
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		code := r.PostFormValue("code")
+		if code == "" {
+			return "", nil
+		}
+		authCode, err := repositories.GetAuthCode(code)
+		if err != nil {
+			return "", ErrInvalidGrant
+		}
+		return authCode.ClientID, nil
+	case "refresh_token":
+		token := r.PostFormValue("refresh_token")
+		if token == "" {
+			return "", nil
+		}
+		if stored, err := repositories.GetRefreshToken(token); err == nil {
+			return stored.ClientID, nil
+		}
+		if rotated, err := repositories.GetRotatedRefreshToken(token); err == nil {
+			return rotated.ClientID, nil
+		}
+		return "", ErrInvalidGrant
+	case JWTBearerGrantType:
+		return assertionIssuer(r), nil
+	}
+	return "", nil
+}
+
+// assertionIssuer returns the unverified issuer of the jwt-bearer assertion
+func assertionIssuer(r *http.Request) string {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(r.PostFormValue("assertion"), jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	issuer, _ := unverified.Claims.(jwt.MapClaims)["iss"].(string)
+	return issuer
+}
@@ -0,0 +1,158 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// TokenExchangeFlow swaps an access token for a narrower one addressed to a
+// downstream audience (RFC 8693). The new token carries an act claim naming
+// the party acting on behalf of the subject
+type TokenExchangeFlow struct{}
+
+func (f *TokenExchangeFlow) Supports(grantType string) bool {
+	return grantType == TokenExchangeGrantType
+}
+
+func (f *TokenExchangeFlow) HandleTokenRequest(w http.ResponseWriter, r *http.Request) {
+	client := tokenClient(r)
+	if !checkDPoPPolicy(w, r, client) {
+		return
+	}
+
+	// Clients without exchange audiences are not allowed to exchange tokens
+	if len(client.TokenExchangeAudiences) == 0 {
+		http.Error(w, "unauthorized_client", http.StatusBadRequest)
+		return
+	}
+
+	if requested := r.FormValue("requested_token_type"); requested != "" && requested != AccessTokenType {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	subject, ok := exchangedTokenClaims(r, r.FormValue("subject_token"), r.FormValue("subject_token_type"))
+	if !ok {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// Without an actor token, the client itself acts for the subject
+	actor := map[string]interface{}{"sub": client.ID}
+	if actorToken := r.FormValue("actor_token"); actorToken != "" {
+		actorClaims, ok := exchangedTokenClaims(r, actorToken, r.FormValue("actor_token_type"))
+		if !ok {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		actor = map[string]interface{}{"sub": actorClaims["sub"]}
+	}
+
+	// Keep the delegation chain of a subject token that was itself exchanged
+	if previous, ok := subject["act"]; ok {
+		actor["act"] = previous
+	}
+
+	audience, ok := exchangeAudience(r.FormValue("audience"), client.TokenExchangeAudiences)
+	if !ok {
+		http.Error(w, "invalid_target", http.StatusBadRequest)
+		return
+	}
+
+	// The new token may only carry scopes of the subject token that the
+	// client is allowed to use. A subject token without scopes gives none,
+	// never the client's defaults
+	subjectScope, _ := subject["scope"].(string)
+	scope, err := NarrowScopes(subjectScope, r.FormValue("scope"))
+	if err == nil && scope != "" {
+		scope, err = ResolveScopes(client, scope)
+	}
+	if err == ErrInvalidScope {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	sub, _ := subject["sub"].(string)
+	claims := accessTokenClaims(sub, client, scope, tokenBinding(r))
+	claims["aud"] = audience
+	claims["act"] = actor
+
+	// Never outlive the subject token
+	if exp, ok := subject["exp"].(float64); ok && int64(exp) < claims["exp"].(int64) {
+		claims["exp"] = int64(exp)
+	}
+
+	accessToken, err := signAccessToken(claims)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"access_token":      accessToken,
+		"issued_token_type": AccessTokenType,
+		"token_type":        tokenBinding(r).tokenType(),
+		"expires_in":        claims["exp"].(int64) - time.Now().Unix(),
+		"scope":             scope,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// exchangedTokenClaims validates a subject or actor token, which must be an
+// access token issued by ZenAuth. A token bound to a DPoP key or a
+// certificate is only accepted when the request proves possession of it
+func exchangedTokenClaims(r *http.Request, token, tokenType string) (jwt.MapClaims, bool) {
+	if token == "" || tokenType != AccessTokenType {
+		return nil, false
+	}
+
+	parsed, err := ValidateAccessToken(token)
+	if err != nil || !parsed.Valid {
+		return nil, false
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, false
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, false
+	}
+	if !tokenBinding(r).proves(claims) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// exchangeAudience checks the requested audience against the client's policy.
+// It may be omitted when the client can only target a single audience
+func exchangeAudience(requested string, allowed []string) (string, bool) {
+	if requested == "" {
+		if len(allowed) == 1 {
+			return allowed[0], true
+		}
+		return "", false
+	}
+
+	for _, a := range allowed {
+		if a == requested {
+			return requested, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,46 @@
+package oauth
+
+import (
+	"time"
+	"zenauth/internal/models"
+)
+
+// userTokenResponse issues the access and refresh tokens of a grant made by
+// a user, plus an id_token when the openid scope was granted
+func userTokenResponse(client *models.Client, userID, scope, nonce string, authTime time.Time, binding TokenBinding) (map[string]interface{}, error) {
+	accessToken, err := GenerateAccessToken(userID, client, scope, binding)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := issueRefreshToken(client, &userID, scope, binding)
+	if err != nil {
+		return nil, err
+	}
+
+	token := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   binding.tokenType(),
+		"expires_in":   int(AccessTokenLifetime(client).Seconds()),
+		"scope":        scope,
+	}
+	if refreshToken != "" {
+		token["refresh_token"] = refreshToken
+	}
+
+	// OpenID Connect: issue an id_token when the openid scope was granted
+	if HasScope(scope, "openid") {
+		user, err := LookupUser(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		idToken, err := GenerateIDToken(user, client.ID, nonce, scope, accessToken, authTime)
+		if err != nil {
+			return nil, err
+		}
+		token["id_token"] = idToken
+	}
+
+	return token, nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import "time"
+
+// RecordAssertionJTI remembers the jti of a JWT assertion until it expires.
+// It returns false when the client already used the jti, which is a replay
+func RecordAssertionJTI(clientID, jti string, expiresAt time.Time) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO client_assertion_jtis (client_id, jti, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (client_id, jti) DO NOTHING`, clientID, jti, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func DeleteExpiredAssertionJTIs() (int64, error) {
+	result, err := db.Exec(`DELETE FROM client_assertion_jtis WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RecordDPoPProofJTI remembers the jti of a DPoP proof until it expires. It
+// returns false when a proof of the same key already used the jti
+func RecordDPoPProofJTI(jkt, jti string, expiresAt time.Time) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO dpop_proof_jtis (jkt, jti, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jkt, jti) DO NOTHING`, jkt, jti, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func DeleteExpiredDPoPProofJTIs() (int64, error) {
+	result, err := db.Exec(`DELETE FROM dpop_proof_jtis WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"time"
+	"zenauth/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// RecordAuditEvent stores a security-relevant event
+func RecordAuditEvent(event *models.AuditEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := db.Exec(`INSERT INTO audit_events (id, type, client_id, user_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		event.ID, event.Type, event.ClientID, event.UserID, event.Details, event.CreatedAt)
+	return err
+}
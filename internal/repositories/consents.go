@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"database/sql"
+	"zenauth/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const consentColumns = `user_id, client_id, scopes, created_at, updated_at`
+
+func scanConsent(row rowScanner) (*models.Consent, error) {
+	var c models.Consent
+	err := row.Scan(&c.UserID, &c.ClientID, pq.Array(&c.Scopes), &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetConsent returns the scopes a user granted to a client
+func GetConsent(userID, clientID string) (*models.Consent, error) {
+	return scanConsent(db.QueryRow(`SELECT `+consentColumns+`
+		FROM user_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID))
+}
+
+// GetUserConsents lists the clients a user granted access to
+func GetUserConsents(userID string) ([]models.Consent, error) {
+	rows, err := db.Query(`SELECT `+consentColumns+`
+		FROM user_consents WHERE user_id = $1 ORDER BY client_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []models.Consent{}
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		consents = append(consents, *consent)
+	}
+	return consents, rows.Err()
+}
+
+// SaveConsent adds scopes to the grant of a user to a client
+func SaveConsent(userID, clientID string, scopes []string) error {
+	_, err := db.Exec(`
+		INSERT INTO user_consents (user_id, client_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT unnest(user_consents.scopes || EXCLUDED.scopes)),
+		    updated_at = now()`, userID, clientID, pq.Array(scopes))
+	return err
+}
+
+// RevokeConsent deletes the grant of a user to a client together with the
+// refresh tokens issued under it. sql.ErrNoRows is returned when no grant exists
+func RevokeConsent(userID, clientID string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM user_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	result, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at = now()
+		WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL`, userID, clientID)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
+
+func StoreConsentRequest(req *models.ConsentRequest) error {
+	_, err := db.Exec(`
+		INSERT INTO consent_requests (id, client_id, redirect_uri, user_id, code_challenge, code_challenge_method,
+			scope, nonce, state, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		req.ID, req.ClientID, req.RedirectURI, req.UserID, req.CodeChallenge, req.CodeChallengeMethod,
+		req.Scope, req.Nonce, req.State, req.AuthTime, req.ExpiresAt)
+	return err
+}
+
+// TakeConsentRequest deletes and returns a pending consent request, so that
+// each request can only be answered once
+func TakeConsentRequest(id string) (*models.ConsentRequest, error) {
+	var req models.ConsentRequest
+	err := db.QueryRow(`
+		DELETE FROM consent_requests WHERE id = $1
+		RETURNING id, client_id, redirect_uri, user_id, code_challenge, code_challenge_method,
+			scope, nonce, state, auth_time, expires_at`, id).
+		Scan(&req.ID, &req.ClientID, &req.RedirectURI, &req.UserID, &req.CodeChallenge, &req.CodeChallengeMethod,
+			&req.Scope, &req.Nonce, &req.State, &req.AuthTime, &req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func DeleteExpiredConsentRequests() (int64, error) {
+	result, err := db.Exec(`DELETE FROM consent_requests WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 	"zenauth/internal/models"
 
 	"github.com/lib/pq"
@@ -19,6 +21,14 @@ func InitPostgres(connStr string) error {
 	return db.Ping()
 }
 
+// ClosePostgres closes the connection pool opened by InitPostgres
+func ClosePostgres() error {
+	if db == nil {
+		return nil
+	}
+	return db.Close()
+}
+
 func GetDB() *sql.DB {
 	return db
 }
@@ -38,19 +48,21 @@ func VerifyPassword(hashed string, password string) bool {
 }
 
 func StoreAuthCode(code *models.AuthCode) error {
-	_, err := db.Exec(`INSERT INTO auth_codes (code, client_id, redirect_uri, user_id, code_challenge, code_challenge_method, expires_at, scope)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+	_, err := db.Exec(`INSERT INTO auth_codes (code, client_id, redirect_uri, user_id, code_challenge, code_challenge_method, expires_at, scope, nonce, auth_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		code.Code, code.ClientID, code.RedirectURI, code.UserID,
-		code.CodeChallenge, code.CodeChallengeMethod, code.ExpiresAt, code.Scope) // Ajouter code.Scope
+		code.CodeChallenge, code.CodeChallengeMethod, code.ExpiresAt, code.Scope, // Ajouter code.Scope
+		code.Nonce, code.AuthTime)
 	return err
 }
 
 func GetAuthCode(code string) (*models.AuthCode, error) {
-	row := db.QueryRow(`SELECT code, client_id, redirect_uri, user_id, code_challenge, code_challenge_method, expires_at, scope
+	row := db.QueryRow(`SELECT code, client_id, redirect_uri, user_id, code_challenge, code_challenge_method, expires_at, scope, nonce, auth_time
 		FROM auth_codes WHERE code = $1`, code)
 
 	var ac models.AuthCode
-	err := row.Scan(&ac.Code, &ac.ClientID, &ac.RedirectURI, &ac.UserID, &ac.CodeChallenge, &ac.CodeChallengeMethod, &ac.ExpiresAt, &ac.Scope) // Ajouter ac.Scope
+	err := row.Scan(&ac.Code, &ac.ClientID, &ac.RedirectURI, &ac.UserID, &ac.CodeChallenge, &ac.CodeChallengeMethod, &ac.ExpiresAt, &ac.Scope, // Ajouter ac.Scope
+		&ac.Nonce, &ac.AuthTime)
 	if err != nil {
 		return nil, err
 	}
@@ -62,31 +74,192 @@ func DeleteAuthCode(code string) error {
 	return err
 }
 
-func GetClientByID(id string) (*models.Client, error) {
-	row := db.QueryRow(`SELECT id, secret, name, redirect_uris FROM clients WHERE id = $1`, id)
+// clientColumns lists the columns read by scanClient, in order
+const clientColumns = `id, secret_hash, secret_expires_at, previous_secret_hash, previous_secret_expires_at, name, redirect_uris, refresh_token_lifetime, refresh_token_idle_timeout, allowed_scopes, consent_exempt,
+	post_logout_redirect_uris, backchannel_logout_uri, frontchannel_logout_uri,
+	token_exchange_audiences, token_endpoint_auth_method, jwks, grant_types, registration_access_token_hash,
+	type, access_token_lifetime, logo_uri, client_uri, policy_uri, tos_uri, require_pushed_authorization_requests, request_uris,
+	dpop_bound_access_tokens, tls_client_auth_subject_dn, tls_client_certificate_thumbprints, jwt_bearer_user_assertions,
+	encrypted_secret, previous_encrypted_secret`
 
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanClient(row rowScanner) (*models.Client, error) {
 	var c models.Client
-	err := row.Scan(&c.ID, &c.Secret, &c.Name, pq.Array(&c.RedirectURIs))
+	err := row.Scan(&c.ID, &c.SecretHash, &c.SecretExpiresAt, &c.PreviousSecretHash, &c.PreviousSecretExpiresAt, &c.Name, pq.Array(&c.RedirectURIs),
+		&c.RefreshTokenLifetime, &c.RefreshTokenIdleTimeout, pq.Array(&c.AllowedScopes), &c.ConsentExempt,
+		pq.Array(&c.PostLogoutRedirectURIs), &c.BackchannelLogoutURI, &c.FrontchannelLogoutURI,
+		pq.Array(&c.TokenExchangeAudiences), &c.TokenEndpointAuthMethod, &c.JWKS,
+		pq.Array(&c.GrantTypes), &c.RegistrationAccessTokenHash,
+		&c.Type, &c.AccessTokenLifetime, &c.LogoURI, &c.ClientURI, &c.PolicyURI, &c.TosURI, &c.RequirePushedAuthorizationRequests, pq.Array(&c.RequestURIs),
+		&c.DPoPBoundAccessTokens, &c.TLSClientAuthSubjectDN, pq.Array(&c.TLSClientCertificateThumbprints), &c.JWTBearerUserAssertions,
+		&c.EncryptedSecret, &c.PreviousEncryptedSecret)
 	if err != nil {
 		return nil, err
 	}
 	return &c, nil
 }
 
-func StoreRefreshToken(token string, clientID string, userID *string) error {
+func GetClientByID(id string) (*models.Client, error) {
+	return scanClient(db.QueryRow(`SELECT `+clientColumns+` FROM clients WHERE id = $1`, id))
+}
+
+var ErrRefreshTokenReused = errors.New("refresh token already rotated")
+
+// refreshTokenColumns lists the columns read by scanRefreshToken, in order
+const refreshTokenColumns = `token, client_id, user_id, COALESCE(family_id, token), scope, issued_at, expires_at, last_used_at, rotated_at, dpop_jkt`
+
+func scanRefreshToken(row rowScanner) (*models.RefreshToken, error) {
+	var rt models.RefreshToken
+	err := row.Scan(&rt.Token, &rt.ClientID, &rt.UserID, &rt.FamilyID, &rt.Scope, &rt.IssuedAt, &rt.ExpiresAt, &rt.LastUsedAt, &rt.RotatedAt, &rt.DPoPJKT)
+	if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+func StoreRefreshToken(rt *models.RefreshToken) error {
+	_, err := db.Exec(`
+		INSERT INTO refresh_tokens (token, client_id, user_id, family_id, scope, expires_at, dpop_jkt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, rt.Token, rt.ClientID, rt.UserID, rt.FamilyID, rt.Scope, rt.ExpiresAt, rt.DPoPJKT)
+	return err
+}
+
+// GetRefreshToken returns a refresh token that is neither revoked nor rotated out
+func GetRefreshToken(token string) (*models.RefreshToken, error) {
+	return scanRefreshToken(db.QueryRow(`SELECT `+refreshTokenColumns+`
+		FROM refresh_tokens WHERE token = $1 AND revoked_at IS NULL AND rotated_at IS NULL`, token))
+}
+
+// GetRotatedRefreshToken returns a refresh token that was already exchanged for a new one
+func GetRotatedRefreshToken(token string) (*models.RefreshToken, error) {
+	return scanRefreshToken(db.QueryRow(`SELECT `+refreshTokenColumns+`
+		FROM refresh_tokens WHERE token = $1 AND rotated_at IS NOT NULL`, token))
+}
+
+// RotateRefreshToken marks oldToken as rotated out and stores its successor.
+// ErrRefreshTokenReused is returned when oldToken was already rotated or revoked
+func RotateRefreshToken(oldToken string, next *models.RefreshToken) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE refresh_tokens SET rotated_at = now(), last_used_at = now()
+		WHERE token = $1 AND rotated_at IS NULL AND revoked_at IS NULL`, oldToken)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (token, client_id, user_id, family_id, scope, expires_at, dpop_jkt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, next.Token, next.ClientID, next.UserID, next.FamilyID, next.Scope, next.ExpiresAt, next.DPoPJKT)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes every token of a rotation family
+func RevokeRefreshTokenFamily(familyID string) (int64, error) {
+	result, err := db.Exec(`UPDATE refresh_tokens SET revoked_at = now()
+		WHERE COALESCE(family_id, token) = $1 AND revoked_at IS NULL`, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func RevokeRefreshToken(token string) error {
+	_, err := db.Exec(`UPDATE refresh_tokens SET revoked_at = now() WHERE token = $1 AND revoked_at IS NULL`, token)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every refresh token a client holds for a user
+func RevokeUserRefreshTokens(userID, clientID string) (int64, error) {
+	result, err := db.Exec(`UPDATE refresh_tokens SET revoked_at = now()
+		WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL`, userID, clientID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeAccessToken denylists an access token jti until the token expires
+func RevokeAccessToken(jti string, expiresAt time.Time) error {
 	_, err := db.Exec(`
-		INSERT INTO refresh_tokens (token, client_id, user_id)
-		VALUES ($1, $2, $3)`, token, clientID, userID)
+		INSERT INTO revoked_access_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
 	return err
 }
 
-func GetRefreshToken(token string) (string, *string, error) {
-	row := db.QueryRow(`SELECT client_id, user_id FROM refresh_tokens WHERE token = $1`, token)
-	var clientID string
-	var userID *string
-	err := row.Scan(&clientID, &userID)
+func IsAccessTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// DeleteExpiredRefreshTokens removes refresh tokens past their absolute expiry,
+// and unused tokens idle for longer than their client's idle timeout
+// (defaultIdle applies to clients without one, 0 disables the idle check).
+// Rotated tokens are kept to detect their reuse until their family has no
+// current token left, since they have no expiry when lifetimes are disabled
+func DeleteExpiredRefreshTokens(defaultIdle time.Duration) (int64, error) {
+	result, err := db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	expired, _ := result.RowsAffected()
+
+	result, err = db.Exec(`
+		DELETE FROM refresh_tokens rt USING clients c
+		WHERE rt.client_id = c.id
+		  AND rt.rotated_at IS NULL
+		  AND COALESCE(NULLIF(c.refresh_token_idle_timeout, 0), $1) > 0
+		  AND COALESCE(rt.last_used_at, rt.issued_at) < now() - make_interval(secs => COALESCE(NULLIF(c.refresh_token_idle_timeout, 0), $1))`,
+		int(defaultIdle.Seconds()))
+	if err != nil {
+		return expired, err
+	}
+	idle, _ := result.RowsAffected()
+
+	result, err = db.Exec(`
+		DELETE FROM refresh_tokens rt
+		WHERE rt.rotated_at IS NOT NULL
+		  AND NOT EXISTS (
+		    SELECT 1 FROM refresh_tokens latest
+		    WHERE COALESCE(latest.family_id, latest.token) = COALESCE(rt.family_id, rt.token)
+		      AND latest.rotated_at IS NULL)`)
+	if err != nil {
+		return expired + idle, err
+	}
+	rotated, _ := result.RowsAffected()
+
+	return expired + idle + rotated, nil
+}
+
+func DeleteExpiredAuthCodes() (int64, error) {
+	result, err := db.Exec(`DELETE FROM auth_codes WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func DeleteExpiredRevokedAccessTokens() (int64, error) {
+	result, err := db.Exec(`DELETE FROM revoked_access_tokens WHERE expires_at < now()`)
 	if err != nil {
-		return "", nil, err
+		return 0, err
 	}
-	return clientID, userID, nil
+	return result.RowsAffected()
 }
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"zenauth/internal/models"
+)
+
+const deviceCodeColumns = `device_code, user_code, client_id, scope, status, user_id, auth_time, interval, last_polled_at, expires_at`
+
+func scanDeviceCode(row rowScanner) (*models.DeviceCode, error) {
+	var d models.DeviceCode
+	err := row.Scan(&d.DeviceCode, &d.UserCode, &d.ClientID, &d.Scope, &d.Status, &d.UserID, &d.AuthTime,
+		&d.Interval, &d.LastPolledAt, &d.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func StoreDeviceCode(d *models.DeviceCode) error {
+	_, err := db.Exec(`
+		INSERT INTO device_codes (device_code, user_code, client_id, scope, status, interval, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		d.DeviceCode, d.UserCode, d.ClientID, d.Scope, d.Status, d.Interval, d.ExpiresAt)
+	return err
+}
+
+func GetDeviceCode(deviceCode string) (*models.DeviceCode, error) {
+	return scanDeviceCode(db.QueryRow(`SELECT `+deviceCodeColumns+` FROM device_codes WHERE device_code = $1`, deviceCode))
+}
+
+// GetPendingDeviceCodeByUserCode returns the unexpired request a user code
+// was issued for, as long as nobody answered it yet
+func GetPendingDeviceCodeByUserCode(userCode string) (*models.DeviceCode, error) {
+	return scanDeviceCode(db.QueryRow(`SELECT `+deviceCodeColumns+` FROM device_codes
+		WHERE user_code = $1 AND status = $2 AND expires_at > now()`, userCode, models.DeviceCodePending))
+}
+
+// CompleteDeviceCode records the user's answer to a pending request.
+// sql.ErrNoRows is returned when the request was already answered
+func CompleteDeviceCode(deviceCode string, status models.DeviceCodeStatus, userID string, authTime time.Time) error {
+	result, err := db.Exec(`UPDATE device_codes SET status = $1, user_id = $2, auth_time = $3
+		WHERE device_code = $4 AND status = $5`, status, userID, authTime, deviceCode, models.DeviceCodePending)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RecordDevicePoll stores the time of the client's last poll and its interval
+func RecordDevicePoll(deviceCode string, interval int) error {
+	_, err := db.Exec(`UPDATE device_codes SET last_polled_at = now(), interval = $1 WHERE device_code = $2`,
+		interval, deviceCode)
+	return err
+}
+
+// DeleteDeviceCode consumes a device code. sql.ErrNoRows is returned when it
+// was already used
+func DeleteDeviceCode(deviceCode string) error {
+	result, err := db.Exec(`DELETE FROM device_codes WHERE device_code = $1`, deviceCode)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteExpiredDeviceCodes() (int64, error) {
+	result, err := db.Exec(`DELETE FROM device_codes WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
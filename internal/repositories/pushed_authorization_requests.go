@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"zenauth/internal/models"
+)
+
+func StorePushedAuthorizationRequest(p *models.PushedAuthorizationRequest) error {
+	_, err := db.Exec(`INSERT INTO pushed_authorization_requests (request_uri, client_id, parameters, expires_at)
+		VALUES ($1, $2, $3, $4)`, p.RequestURI, p.ClientID, p.Parameters, p.ExpiresAt)
+	return err
+}
+
+// GetPushedAuthorizationRequest returns an unexpired pushed request
+func GetPushedAuthorizationRequest(requestURI string) (*models.PushedAuthorizationRequest, error) {
+	var p models.PushedAuthorizationRequest
+	err := db.QueryRow(`SELECT request_uri, client_id, parameters, expires_at FROM pushed_authorization_requests
+		WHERE request_uri = $1 AND expires_at > now()`, requestURI).Scan(&p.RequestURI, &p.ClientID, &p.Parameters, &p.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func DeletePushedAuthorizationRequest(requestURI string) error {
+	_, err := db.Exec(`DELETE FROM pushed_authorization_requests WHERE request_uri = $1`, requestURI)
+	return err
+}
+
+func DeleteExpiredPushedAuthorizationRequests() (int64, error) {
+	result, err := db.Exec(`DELETE FROM pushed_authorization_requests WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
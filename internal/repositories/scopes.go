@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"time"
+	"zenauth/internal/models"
+)
+
+// GetAllScopes returns every registered scope
+func GetAllScopes() ([]models.Scope, error) {
+	rows, err := db.Query("SELECT name, description, created_at FROM scopes ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scopes []models.Scope
+	for rows.Next() {
+		var scope models.Scope
+		var createdAt time.Time
+		if err := rows.Scan(&scope.Name, &scope.Description, &createdAt); err != nil {
+			return nil, err
+		}
+		scope.CreatedAt = createdAt.Format(time.RFC3339)
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// GetScopeByName retrieves a registered scope
+func GetScopeByName(name string) (*models.Scope, error) {
+	var scope models.Scope
+	var createdAt time.Time
+	err := db.QueryRow("SELECT name, description, created_at FROM scopes WHERE name = $1", name).
+		Scan(&scope.Name, &scope.Description, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	scope.CreatedAt = createdAt.Format(time.RFC3339)
+	return &scope, nil
+}
+
+// CreateScope registers a new scope
+func CreateScope(name, description string) (*models.Scope, error) {
+	now := time.Now()
+	_, err := db.Exec("INSERT INTO scopes (name, description, created_at) VALUES ($1, $2, $3)",
+		name, description, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Scope{
+		Name:        name,
+		Description: description,
+		CreatedAt:   now.Format(time.RFC3339),
+	}, nil
+}
+
+// UpdateScope changes the description of a scope
+func UpdateScope(name, description string) error {
+	_, err := db.Exec("UPDATE scopes SET description = $1 WHERE name = $2", description, name)
+	return err
+}
+
+// DeleteScope removes a scope and drops it from the clients allowed to request it
+func DeleteScope(name string) error {
+	_, err := db.Exec("UPDATE clients SET allowed_scopes = array_remove(allowed_scopes, $1)", name)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM scopes WHERE name = $1", name)
+	return err
+}
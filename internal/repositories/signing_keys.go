@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"time"
+	"zenauth/internal/models"
+)
+
+// signingKeyRotationLock is the Postgres advisory lock serialising rotations
+const signingKeyRotationLock = 0x7a656e61757468
+
+// WithSigningKeyRotationLock runs fn while holding an advisory lock shared by
+// every instance, so that a single one rotates the signing keys at a time.
+// The lock is released with its transaction, even when fn fails
+func WithSigningKeyRotationLock(fn func() error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyRotationLock); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateSigningKey stores a new signing key
+func CreateSigningKey(key *models.SigningKey) error {
+	_, err := db.Exec(`INSERT INTO signing_keys (id, algorithm, private_key, state, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		key.ID, key.Algorithm, key.PrivateKey, key.State, key.CreatedAt)
+	return err
+}
+
+// GetSigningKeys returns all signing keys that can still validate tokens, newest first
+func GetSigningKeys() ([]models.SigningKey, error) {
+	rows, err := db.Query(`SELECT id, algorithm, private_key, state, created_at, activated_at, retired_at, expires_at
+		FROM signing_keys
+		WHERE state <> 'retired' OR expires_at > now()
+		ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var key models.SigningKey
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.State, &key.CreatedAt,
+			&key.ActivatedAt, &key.RetiredAt, &key.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ActivateSigningKey makes the given key the active one and retires the previous
+// active key, which keeps validating tokens until retiredUntil
+func ActivateSigningKey(id string, retiredUntil time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE signing_keys SET state = 'retired', retired_at = $1, expires_at = $2
+		WHERE state = 'active' AND id <> $3`, now, retiredUntil, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE signing_keys SET state = 'active', activated_at = $1 WHERE id = $2`, now, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpiredSigningKeys removes retired keys that can no longer validate any token
+func DeleteExpiredSigningKeys() (int64, error) {
+	result, err := db.Exec(`DELETE FROM signing_keys WHERE state = 'retired' AND expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
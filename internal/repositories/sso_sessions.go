@@ -0,0 +1,44 @@
+package repositories
+
+import "zenauth/internal/models"
+
+func CreateSSOSession(session *models.SSOSession) error {
+	_, err := db.Exec(`INSERT INTO sso_sessions (id, user_id, auth_time, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		session.ID, session.UserID, session.AuthTime, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+// GetSSOSession returns a session that has not expired yet
+func GetSSOSession(id string) (*models.SSOSession, error) {
+	var s models.SSOSession
+	err := db.QueryRow(`SELECT id, user_id, auth_time, created_at, expires_at
+		FROM sso_sessions WHERE id = $1 AND expires_at > now()`, id).
+		Scan(&s.ID, &s.UserID, &s.AuthTime, &s.CreatedAt, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func DeleteSSOSession(id string) error {
+	_, err := db.Exec(`DELETE FROM sso_sessions WHERE id = $1`, id)
+	return err
+}
+
+// DeleteUserSSOSessions signs a user out of every browser
+func DeleteUserSSOSessions(userID string) (int64, error) {
+	result, err := db.Exec(`DELETE FROM sso_sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func DeleteExpiredSSOSessions() (int64, error) {
+	result, err := db.Exec(`DELETE FROM sso_sessions WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
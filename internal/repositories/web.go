@@ -57,9 +57,9 @@ func CreateUser(username, password string) (*models.User, error) {
 
 // GetUserByID retrieves a user by their ID
 func GetUserByID(id string) (*models.User, error) {
-	row := db.QueryRow("SELECT id, username, password_hash FROM users WHERE id = $1", id)
+	row := db.QueryRow("SELECT id, username, password_hash, COALESCE(email, '') FROM users WHERE id = $1", id)
 	var user models.User
-	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +86,26 @@ func UpdateUser(id, username string, newPassword *string) error {
 	return err
 }
 
-// DeleteUser deletes a user by ID
+// DeleteUser deletes a user by ID, with the grants and tokens issued to them
 func DeleteUser(id string) error {
-	_, err := db.Exec("DELETE FROM users WHERE id = $1", id)
+	_, err := db.Exec("DELETE FROM user_consents WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Refresh tokens of every client stop working with the account
+	_, err = db.Exec("DELETE FROM refresh_tokens WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// And so do authorization codes not redeemed yet
+	_, err = db.Exec("DELETE FROM auth_codes WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM users WHERE id = $1", id)
 	return err
 }
 
@@ -96,7 +113,7 @@ func DeleteUser(id string) error {
 
 // GetAllClients returns a list of all OAuth clients
 func GetAllClients() ([]models.Client, error) {
-	rows, err := db.Query("SELECT id, secret, name, redirect_uris FROM clients")
+	rows, err := db.Query("SELECT " + clientColumns + " FROM clients")
 	if err != nil {
 		return nil, err
 	}
@@ -104,20 +121,20 @@ func GetAllClients() ([]models.Client, error) {
 
 	var clients []models.Client
 	for rows.Next() {
-		var client models.Client
-		if err := rows.Scan(&client.ID, &client.Secret, &client.Name, pq.Array(&client.RedirectURIs)); err != nil {
+		client, err := scanClient(rows)
+		if err != nil {
 			return nil, err
 		}
-		clients = append(clients, client)
+		clients = append(clients, *client)
 	}
 	return clients, nil
 }
 
 // CreateClient creates a new OAuth client
-func CreateClient(id, secret, name string, redirectURIs []string) (*models.Client, error) {
+func CreateClient(client *models.Client) (*models.Client, error) {
 	// Check if client ID already exists
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM clients WHERE id = $1", id).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM clients WHERE id = $1", client.ID).Scan(&count)
 	if err != nil {
 		return nil, err
 	}
@@ -126,33 +143,79 @@ func CreateClient(id, secret, name string, redirectURIs []string) (*models.Clien
 	}
 
 	// Insert the client
-	_, err = db.Exec("INSERT INTO clients (id, secret, name, redirect_uris) VALUES ($1, $2, $3, $4)",
-		id, secret, name, pq.Array(redirectURIs))
+	_, err = db.Exec(`INSERT INTO clients (id, secret_hash, secret_expires_at, previous_secret_hash, previous_secret_expires_at, name, redirect_uris,
+		refresh_token_lifetime, refresh_token_idle_timeout, allowed_scopes, consent_exempt,
+		post_logout_redirect_uris, backchannel_logout_uri, frontchannel_logout_uri, token_exchange_audiences, token_endpoint_auth_method, jwks,
+		grant_types, registration_access_token_hash, type, access_token_lifetime, logo_uri, client_uri, policy_uri, tos_uri,
+		require_pushed_authorization_requests, request_uris, dpop_bound_access_tokens,
+		tls_client_auth_subject_dn, tls_client_certificate_thumbprints, jwt_bearer_user_assertions, encrypted_secret, previous_encrypted_secret)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28,
+		$29, $30, $31, $32, $33)`,
+		client.ID, client.SecretHash, client.SecretExpiresAt, client.PreviousSecretHash, client.PreviousSecretExpiresAt,
+		client.Name, pq.Array(client.RedirectURIs),
+		client.RefreshTokenLifetime, client.RefreshTokenIdleTimeout, pq.Array(client.AllowedScopes), client.ConsentExempt,
+		pq.Array(client.PostLogoutRedirectURIs), client.BackchannelLogoutURI, client.FrontchannelLogoutURI,
+		pq.Array(client.TokenExchangeAudiences), client.TokenEndpointAuthMethod, client.JWKS,
+		pq.Array(client.GrantTypes), client.RegistrationAccessTokenHash,
+		client.Type, client.AccessTokenLifetime, client.LogoURI, client.ClientURI, client.PolicyURI, client.TosURI,
+		client.RequirePushedAuthorizationRequests, pq.Array(client.RequestURIs), client.DPoPBoundAccessTokens,
+		client.TLSClientAuthSubjectDN, pq.Array(client.TLSClientCertificateThumbprints), client.JWTBearerUserAssertions,
+		client.EncryptedSecret, client.PreviousEncryptedSecret)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Client{
-		ID:           id,
-		Secret:       secret,
-		Name:         name,
-		RedirectURIs: redirectURIs,
-	}, nil
+	return client, nil
 }
 
-// UpdateClient updates an OAuth client
-func UpdateClient(id, name string, secret *string, redirectURIs []string) error {
-	if secret == nil {
-		// Only update name and redirect URIs
-		_, err := db.Exec("UPDATE clients SET name = $1, redirect_uris = $2 WHERE id = $3",
-			name, pq.Array(redirectURIs), id)
-		return err
+// UpdateClient updates an OAuth client, including its secret hashes
+func UpdateClient(client *models.Client) error {
+	_, err := db.Exec(`UPDATE clients SET name = $1, redirect_uris = $2, refresh_token_lifetime = $3, refresh_token_idle_timeout = $4,
+		allowed_scopes = $5, consent_exempt = $6, post_logout_redirect_uris = $7,
+		backchannel_logout_uri = $8, frontchannel_logout_uri = $9, token_exchange_audiences = $10,
+		token_endpoint_auth_method = $11, jwks = $12,
+		secret_hash = $13, secret_expires_at = $14, previous_secret_hash = $15, previous_secret_expires_at = $16,
+		grant_types = $17, registration_access_token_hash = $18,
+		type = $19, access_token_lifetime = $20, logo_uri = $21, client_uri = $22, policy_uri = $23, tos_uri = $24,
+		require_pushed_authorization_requests = $25, request_uris = $26, dpop_bound_access_tokens = $27,
+		tls_client_auth_subject_dn = $28, tls_client_certificate_thumbprints = $29, jwt_bearer_user_assertions = $30,
+		encrypted_secret = $31, previous_encrypted_secret = $32 WHERE id = $33`,
+		client.Name, pq.Array(client.RedirectURIs),
+		client.RefreshTokenLifetime, client.RefreshTokenIdleTimeout, pq.Array(client.AllowedScopes),
+		client.ConsentExempt, pq.Array(client.PostLogoutRedirectURIs),
+		client.BackchannelLogoutURI, client.FrontchannelLogoutURI, pq.Array(client.TokenExchangeAudiences),
+		client.TokenEndpointAuthMethod, client.JWKS,
+		client.SecretHash, client.SecretExpiresAt, client.PreviousSecretHash, client.PreviousSecretExpiresAt,
+		pq.Array(client.GrantTypes), client.RegistrationAccessTokenHash,
+		client.Type, client.AccessTokenLifetime, client.LogoURI, client.ClientURI, client.PolicyURI, client.TosURI,
+		client.RequirePushedAuthorizationRequests, pq.Array(client.RequestURIs), client.DPoPBoundAccessTokens,
+		client.TLSClientAuthSubjectDN, pq.Array(client.TLSClientCertificateThumbprints), client.JWTBearerUserAssertions,
+		client.EncryptedSecret, client.PreviousEncryptedSecret, client.ID)
+	return err
+}
+
+// GetUserSignedInClients returns the clients a user holds a grant or an
+// active refresh token for, which are the ones to notify on logout
+func GetUserSignedInClients(userID string) ([]models.Client, error) {
+	rows, err := db.Query(`SELECT `+clientColumns+` FROM clients WHERE id IN (
+			SELECT client_id FROM user_consents WHERE user_id = $1
+			UNION
+			SELECT client_id FROM refresh_tokens WHERE user_id = $1 AND revoked_at IS NULL
+		)`, userID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Update name, secret, and redirect URIs
-	_, err := db.Exec("UPDATE clients SET name = $1, secret = $2, redirect_uris = $3 WHERE id = $4",
-		name, *secret, pq.Array(redirectURIs), id)
-	return err
+	var clients []models.Client
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+	return clients, rows.Err()
 }
 
 // DeleteClient deletes an OAuth client by ID
@@ -169,6 +232,12 @@ func DeleteClient(id string) error {
 		return err
 	}
 
+	// And the consents users granted to the client
+	_, err = db.Exec("DELETE FROM user_consents WHERE client_id = $1", id)
+	if err != nil {
+		return err
+	}
+
 	// Finally delete the client itself
 	_, err = db.Exec("DELETE FROM clients WHERE id = $1", id)
 	return err
@@ -4,6 +4,7 @@ package router
 import (
 	"net/http"
 
+	"zenauth/config"
 	"zenauth/internal/handlers"
 	"zenauth/internal/middlewares"
 	"zenauth/internal/oauth"
@@ -38,13 +39,14 @@ func New(oauthFlows []oauth.OAuthFlow) *Router {
 	adminLoginRouter := adminBase.PathPrefix("").Subrouter()
 	adminLoginRouter.HandleFunc("/login", handlers.AdminLoginPageHandler).Methods("GET")
 	adminLoginRouter.HandleFunc("/login/submit", handlers.AdminLoginHandler).Methods("POST")
-	// adminLoginRouter.HandleFunc("/logout", handlers.AdminLogoutHandler).Methods("POST")  // Uncomment when implemented
+	adminLoginRouter.HandleFunc("/logout", handlers.AdminLogoutHandler).Methods("POST")
 
 	// Protected admin routes with auth middleware
 	r.admin = adminBase.PathPrefix("").Subrouter()
 	r.admin.Use(middlewares.AdminAuthMiddleware)
 
 	r.setupRoutes()
+	handlers.RegisterEndpoints(r.endpoints())
 	return r
 }
 
@@ -62,10 +64,27 @@ func (r *Router) setupRoutes() {
 
 // setupPublicRoutes configures public-facing routes
 func (r *Router) setupPublicRoutes() {
-	// OAuth endpoints
-	r.public.HandleFunc("/authorize", handlers.AuthorizeHandler).Methods("GET", "POST")
-	r.public.Handle("/token", middlewares.WithCORS(http.HandlerFunc(handlers.TokenHandler))).Methods("POST")
-	r.public.Handle("/userinfo", middlewares.WithCORS(http.HandlerFunc(handlers.UserInfoHandler))).Methods("GET")
+	// OAuth endpoints, named after their discovery metadata
+	r.public.HandleFunc("/authorize", handlers.AuthorizeHandler).Methods("GET", "POST").Name("authorization_endpoint")
+	r.public.Handle("/par", middlewares.WithCORS(http.HandlerFunc(handlers.PushedAuthorizationHandler))).Methods("POST").Name("pushed_authorization_request_endpoint")
+	r.public.HandleFunc("/authorize/consent", handlers.ConsentHandler).Methods("POST")
+	r.public.Handle("/token", middlewares.WithCORS(http.HandlerFunc(handlers.TokenHandler))).Methods("POST").Name("token_endpoint")
+	r.public.Handle("/device_authorization", middlewares.WithCORS(http.HandlerFunc(handlers.DeviceAuthorizationHandler))).Methods("POST").Name("device_authorization_endpoint")
+	r.public.HandleFunc("/device", handlers.DeviceVerificationHandler).Methods("GET", "POST")
+	r.public.Handle("/revoke", middlewares.WithCORS(http.HandlerFunc(handlers.RevokeHandler))).Methods("POST").Name("revocation_endpoint")
+	r.public.Handle("/introspect", middlewares.WithCORS(http.HandlerFunc(handlers.IntrospectHandler))).Methods("POST").Name("introspection_endpoint")
+	r.public.HandleFunc("/logout", handlers.EndSessionHandler).Methods("GET", "POST").Name("end_session_endpoint")
+	r.public.Handle("/userinfo", middlewares.WithCORS(http.HandlerFunc(handlers.UserInfoHandler))).Methods("GET").Name("userinfo_endpoint")
+	r.public.Handle("/.well-known/jwks.json", middlewares.WithCORS(http.HandlerFunc(handlers.JWKSHandler))).Methods("GET").Name("jwks_uri")
+	if config.App.Registration.Enabled {
+		r.public.Handle("/register", middlewares.WithCORS(http.HandlerFunc(handlers.RegistrationHandler))).Methods("POST").Name("registration_endpoint")
+		r.public.Handle("/register/{client_id}", middlewares.WithCORS(http.HandlerFunc(handlers.ClientConfigurationHandler))).Methods("GET", "PUT", "DELETE")
+	}
+	r.public.Handle("/.well-known/openid-configuration", middlewares.WithCORS(http.HandlerFunc(handlers.DiscoveryHandler))).Methods("GET")
+
+	// Grants the token's user gave to clients
+	r.public.Handle("/consents", middlewares.WithCORS(http.HandlerFunc(handlers.UserConsentsHandler))).Methods("GET")
+	r.public.Handle("/consents/{client_id}", middlewares.WithCORS(http.HandlerFunc(handlers.UserConsentHandler))).Methods("DELETE")
 
 	// External auth endpoints
 	r.public.HandleFunc("/auth/external", handlers.StartExternalAuth).Methods("GET")
@@ -94,6 +113,8 @@ func (r *Router) setupAdminRoutes() {
 	r.admin.HandleFunc("/users/{id}", handlers.AdminUserHandler).Methods("GET", "PUT", "DELETE")
 	r.admin.HandleFunc("/blocked-users", handlers.AdminBlockedUsersHandler).Methods("GET")
 	r.admin.HandleFunc("/unblock-user", handlers.AdminUnblockUserHandler).Methods("POST")
+	r.admin.HandleFunc("/users/{id}/consents", handlers.AdminUserConsentsHandler).Methods("GET")
+	r.admin.HandleFunc("/users/{id}/consents/{client_id}", handlers.AdminUserConsentHandler).Methods("DELETE")
 
 	// Client management
 	r.admin.HandleFunc("/clients", handlers.AdminClientsHandler).Methods("GET", "POST")
@@ -111,6 +132,14 @@ func (r *Router) setupAdminRoutes() {
 	r.admin.HandleFunc("/users-roles", handlers.AdminUserRolesHandler).Methods("GET", "POST", "DELETE")
 	r.admin.HandleFunc("/users-groups", handlers.AdminUserGroupsHandler).Methods("GET", "POST", "DELETE")
 
+	// Scope management
+	r.admin.HandleFunc("/scopes", handlers.AdminScopesHandler).Methods("GET", "POST")
+	r.admin.HandleFunc("/scopes/{name}", handlers.AdminScopeHandler).Methods("GET", "PUT", "DELETE")
+
+	// Token signing keys
+	r.admin.HandleFunc("/signing-keys", handlers.AdminSigningKeysHandler).Methods("GET")
+	r.admin.HandleFunc("/signing-keys/rotate", handlers.AdminRotateSigningKeyHandler).Methods("POST")
+
 	// Auth providers
 	r.admin.HandleFunc("/auth-providers", handlers.ListAuthProviders).Methods("GET")
 	r.admin.HandleFunc("/auth-providers", handlers.CreateAuthProvider).Methods("POST")
@@ -119,6 +148,22 @@ func (r *Router) setupAdminRoutes() {
 	r.admin.HandleFunc("/auth-providers/{id}", handlers.DeleteAuthProvider).Methods("DELETE")
 }
 
+// endpoints collects the paths of the named protocol routes for the discovery document
+func (r *Router) endpoints() map[string]string {
+	endpoints := map[string]string{}
+	r.root.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		name := route.GetName()
+		if name == "" {
+			return nil
+		}
+		if path, err := route.GetPathTemplate(); err == nil {
+			endpoints[name] = path
+		}
+		return nil
+	})
+	return endpoints
+}
+
 // setupAPIRoutes configures API endpoints
 func (r *Router) setupAPIRoutes() {
 	// Add API routes here when needed
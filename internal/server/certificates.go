@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"zenauth/config"
+)
+
+// certificateReloader serves the TLS certificate, reloaded from its files on
+// SIGHUP or when they change, so certificates can be renewed without a restart
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	c := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the certificate and its key. The current certificate is kept
+// when they cannot be loaded, e.g. while only one of the files was replaced
+func (c *certificateReloader) reload() error {
+	modTimes, err := c.fileModTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTimes = modTimes
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *certificateReloader) fileModTimes() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// changed reports whether the files were modified since the last reload
+func (c *certificateReloader) changed() bool {
+	modTimes, err := c.fileModTimes()
+	if err != nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return modTimes != c.modTimes
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// watch reloads the certificate on SIGHUP and, when a reload interval is
+// configured, when its files change, until stop is closed
+func (c *certificateReloader) watch(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval := config.App.TLS.ReloadInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+		case <-tick:
+			if !c.changed() {
+				continue
+			}
+		}
+
+		if err := c.reload(); err != nil {
+			log.Printf("Failed to reload TLS certificate, keeping the current one: %v", err)
+			continue
+		}
+		log.Println("🔐 TLS certificate reloaded")
+	}
+}
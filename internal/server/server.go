@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"zenauth/config"
+)
+
+// Server is the HTTP server of ZenAuth: plain HTTP, or HTTPS with an optional
+// listener redirecting plain HTTP requests to it
+type Server struct {
+	http     *http.Server
+	redirect *http.Server
+	certs    *certificateReloader
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New configures the server from config.App
+func New(handler http.Handler) (*Server, error) {
+	s := &Server{
+		http: &http.Server{
+			Addr:              ":" + config.App.ServerPort,
+			Handler:           handler,
+			ReadHeaderTimeout: config.App.Server.ReadHeaderTimeout,
+			ReadTimeout:       config.App.Server.ReadTimeout,
+			WriteTimeout:      config.App.Server.WriteTimeout,
+			IdleTimeout:       config.App.Server.IdleTimeout,
+		},
+		stop: make(chan struct{}),
+	}
+
+	if !TLSEnabled() {
+		return s, nil
+	}
+
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	s.certs, err = newCertificateReloader(config.App.TLS.CertFile, config.App.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig.GetCertificate = s.certs.GetCertificate
+	s.http.TLSConfig = tlsConfig
+
+	if config.App.TLS.RedirectPort != "" {
+		s.redirect = &http.Server{
+			Addr:              ":" + config.App.TLS.RedirectPort,
+			Handler:           http.HandlerFunc(redirectToHTTPS),
+			ReadHeaderTimeout: config.App.Server.ReadHeaderTimeout,
+			ReadTimeout:       config.App.Server.ReadTimeout,
+			WriteTimeout:      config.App.Server.WriteTimeout,
+			IdleTimeout:       config.App.Server.IdleTimeout,
+		}
+	}
+	return s, nil
+}
+
+// TLSEnabled reports whether the server serves HTTPS
+func TLSEnabled() bool {
+	return config.App.TLS.CertFile != ""
+}
+
+// ListenAndServe serves requests until the server is shut down, which
+// returns nil
+func (s *Server) ListenAndServe() error {
+	if s.certs == nil {
+		log.Printf("🚀 OAuth server running on http://localhost:%s", config.App.ServerPort)
+		return ignoreClosed(s.http.ListenAndServe())
+	}
+
+	go s.certs.watch(s.stop)
+
+	if s.redirect != nil {
+		go func() {
+			log.Printf("↪️  Redirecting http://localhost:%s to HTTPS", config.App.TLS.RedirectPort)
+			if err := ignoreClosed(s.redirect.ListenAndServe()); err != nil {
+				log.Printf("HTTP redirect listener failed: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("🚀 OAuth server running on https://localhost:%s", config.App.ServerPort)
+	return ignoreClosed(s.http.ListenAndServeTLS("", ""))
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// complete, until ctx is done. It may be called more than once
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	var redirectErr error
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			redirectErr = fmt.Errorf("failed to shut down the HTTP redirect listener: %w", err)
+		}
+	}
+	return errors.Join(s.http.Shutdown(ctx), redirectErr)
+}
+
+func ignoreClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL on the HTTPS port
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if config.App.ServerPort != "443" {
+		host = net.JoinHostPort(host, config.App.ServerPort)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// newTLSConfig applies the protocol version and cipher suite policy.
+// Client certificates are requested but not required, they are only
+// verified when a client authenticates with them (RFC 8705)
+func newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ClientAuth: tls.RequestClientCert,
+	}
+
+	switch config.App.TLS.MinVersion {
+	case "", "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS minimum version: %s", config.App.TLS.MinVersion)
+	}
+
+	// Only secure suites may be configured, TLS 1.3 suites are always enabled
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range config.App.TLS.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS cipher suite: %s", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+	return tlsConfig, nil
+}
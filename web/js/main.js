@@ -26,7 +26,7 @@ const elements = {
     clientIdInput: document.getElementById('client-id-input'),
     clientId: document.getElementById('client-id'),
     clientName: document.getElementById('client-name'),
-    clientSecret: document.getElementById('client-secret'),
+    clientRotateSecret: document.getElementById('client-rotate-secret'),
     redirectUris: document.getElementById('redirect-uris'),
 
     // Provider elements
@@ -227,7 +227,7 @@ function attachClientEventHandlers() {
     // Handlers for edit buttons
     document.querySelectorAll('#clients-list .action-btn.edit').forEach(btn => {
         btn.addEventListener('click', () => {
-            showEditClientModal(btn.dataset.id, btn.dataset.name, btn.dataset.secret, btn.dataset.uris);
+            showEditClientModal(btn.dataset.id, btn.dataset.name, btn.dataset.uris);
         });
     });
 
@@ -325,28 +325,27 @@ function showAddClientModal() {
     elements.clientId.value = '';
     elements.clientId.readOnly = false;
     elements.clientName.value = '';
-    elements.clientSecret.value = '';
-    elements.clientSecret.required = true;
+    elements.clientRotateSecret.checked = false;
 
-    if (document.getElementById('secret-hint')) {
-        document.getElementById('secret-hint').style.display = 'none';
+    // A secret is always generated for new clients
+    if (document.getElementById('rotate-secret-group')) {
+        document.getElementById('rotate-secret-group').style.display = 'none';
     }
 
     elements.redirectUris.value = '';
     uiManager.toggleModal(elements.clientModal, true);
 }
 
-function showEditClientModal(id, name, secret, uris) {
+function showEditClientModal(id, name, uris) {
     elements.clientModalTitle.textContent = 'Edit OAuth Client';
     elements.clientIdInput.value = id;
     elements.clientId.value = id;
     elements.clientId.readOnly = true;
     elements.clientName.value = name;
-    elements.clientSecret.value = '';
-    elements.clientSecret.required = false;
+    elements.clientRotateSecret.checked = false;
 
-    if (document.getElementById('secret-hint')) {
-        document.getElementById('secret-hint').style.display = 'block';
+    if (document.getElementById('rotate-secret-group')) {
+        document.getElementById('rotate-secret-group').style.display = 'block';
     }
 
     elements.redirectUris.value = uris;
@@ -440,18 +439,24 @@ async function handleClientFormSubmit(e) {
         redirect_uris: redirectUrisList
     };
 
-    if (elements.clientSecret.value) {
-        clientData.secret = elements.clientSecret.value;
+    if (isEditing && elements.clientRotateSecret.checked) {
+        clientData.rotate_secret = true;
     }
 
     try {
+        let result;
         if (isEditing) {
-            await clientManager.updateClient(elements.clientIdInput.value, clientData);
+            result = await clientManager.updateClient(elements.clientIdInput.value, clientData);
         } else {
-            await clientManager.createClient(clientData);
+            result = await clientManager.createClient(clientData);
         }
 
         uiManager.toggleModal(elements.clientModal, false);
+
+        // Generated secrets are only returned once
+        if (result && result.secret) {
+            window.prompt('Copy the client secret now, it will not be shown again:', result.secret);
+        }
     } catch (error) {
         // Error is already handled in clientManager methods
     }
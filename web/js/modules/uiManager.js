@@ -211,7 +211,7 @@ export function renderClientsList(clients, container) {
             <td>${redirectUrisText}</td>
             <td>
                 <button class="action-btn edit" data-id="${client.ID}" data-name="${client.Name}" 
-                        data-secret="${client.Secret}" data-uris="${Array.isArray(client.RedirectURIs) ? client.RedirectURIs.join('\n') : ''}">
+                        data-uris="${Array.isArray(client.RedirectURIs) ? client.RedirectURIs.join('\n') : ''}">
                     <i class="fas fa-edit"></i> Edit
                 </button>
                 <button class="action-btn delete" data-id="${client.ID}" data-type="client" data-name="${client.Name}">